@@ -0,0 +1,141 @@
+package plugapi
+
+import (
+	"time"
+)
+
+// How often we look for idle users, if Config.IdleTimeout is set
+const idleCheckInterval = 10 * time.Second
+
+// Activity is what a user in the room has been up to. Users that
+// were already in the room when we joined it joined at that time.
+type Activity struct {
+	Joined   time.Time
+	LastChat time.Time // zero if they haven't chatted
+	LastVote time.Time // zero if they haven't voted
+
+	idleSent bool // has a UserIdleEvent been sent since they were last active
+}
+
+// LastActive returns the last time the user did anything
+func (a Activity) LastActive() time.Time {
+	last := a.Joined
+	if a.LastChat.After(last) {
+		last = a.LastChat
+	}
+	if a.LastVote.After(last) {
+		last = a.LastVote
+	}
+	return last
+}
+
+// Activity returns what the user has been up to, and
+// false if they aren't in the room (or we don't know)
+func (plug *PlugDJ) Activity(userID int) (Activity, bool) {
+	plug.activityLock.RLock()
+	defer plug.activityLock.RUnlock()
+
+	activity, ok := plug.activity[userID]
+	if !ok {
+		return Activity{}, false
+	}
+	return *activity, true
+}
+
+// IdleFor returns how long it has been since the user last joined,
+// chatted or voted, and false if they aren't in the room
+func (plug *PlugDJ) IdleFor(userID int) (time.Duration, bool) {
+	activity, ok := plug.Activity(userID)
+	if !ok {
+		return 0, false
+	}
+	return time.Since(activity.LastActive()), true
+}
+
+// IdleDJs returns the users in the waitlist that have been
+// idle for longer than idle, in the order of the waitlist
+func (plug *PlugDJ) IdleDJs(idle time.Duration) []User {
+	var users []User
+	for _, user := range plug.Room.getDJs() {
+		if idleFor, ok := plug.IdleFor(user.ID); ok && idleFor > idle {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+// updateActivity changes what we know about the user
+func (plug *PlugDJ) updateActivity(userID int, fn func(activity *Activity)) {
+	plug.activityLock.Lock()
+	defer plug.activityLock.Unlock()
+
+	activity, ok := plug.activity[userID]
+	if !ok {
+		activity = &Activity{Joined: time.Now()}
+		plug.activity[userID] = activity
+	}
+
+	fn(activity)
+	activity.idleSent = false
+}
+
+// forgetActivity forgets about a user that left
+func (plug *PlugDJ) forgetActivity(userID int) {
+	plug.activityLock.Lock()
+	defer plug.activityLock.Unlock()
+
+	delete(plug.activity, userID)
+}
+
+// resetActivity starts over with the users in a room we just joined
+func (plug *PlugDJ) resetActivity(users []User) {
+	now := time.Now()
+	activity := make(map[int]*Activity, len(users))
+	for _, user := range users {
+		activity[user.ID] = &Activity{Joined: now}
+	}
+
+	plug.activityLock.Lock()
+	plug.activity = activity
+	plug.activityLock.Unlock()
+}
+
+// watchIdle sends a UserIdleEvent for every user in the waitlist
+// that has been idle for Config.IdleTimeout, until closer is closed
+func (plug *PlugDJ) watchIdle(closer chan struct{}) {
+	timeout := plug.config.IdleTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closer:
+			return
+		case <-ticker.C:
+		}
+
+		for _, user := range plug.IdleDJs(timeout) {
+			plug.activityLock.Lock()
+			activity, ok := plug.activity[user.ID]
+			send := ok && !activity.idleSent
+			var lastActive time.Time
+			if send {
+				activity.idleSent = true
+				lastActive = activity.LastActive()
+			}
+			plug.activityLock.Unlock()
+
+			if send {
+				plug.emitEvent(UserIdleEvent, UserIdlePayload{
+					User:     user,
+					IdleFor:  time.Since(lastActive),
+					Position: plug.Room.waitlistPosition(user.ID),
+				})
+			}
+		}
+	}
+}
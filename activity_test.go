@@ -0,0 +1,86 @@
+package plugapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLastActive(t *testing.T) {
+	joined := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		activity Activity
+		want     time.Time
+	}{
+		{Activity{Joined: joined}, joined},
+		{Activity{Joined: joined, LastChat: joined.Add(time.Minute)}, joined.Add(time.Minute)},
+		{Activity{Joined: joined, LastChat: joined.Add(time.Minute), LastVote: joined.Add(2 * time.Minute)}, joined.Add(2 * time.Minute)},
+		{Activity{Joined: joined, LastVote: joined.Add(-time.Minute)}, joined},
+	}
+
+	for _, test := range tests {
+		if got := test.activity.LastActive(); !got.Equal(test.want) {
+			t.Errorf("%+v.LastActive() = %s, want %s", test.activity, got, test.want)
+		}
+	}
+}
+
+func TestActivity(t *testing.T) {
+	plug := newPlaybackPlug(Config{})
+	plug.activity = make(map[int]*Activity)
+	plug.resetActivity(plug.Room.GetUsers())
+	votes := listenFor(plug, VoteEvent)
+
+	if _, ok := plug.Activity(2); !ok {
+		t.Fatal("no activity for a user that was in the room when we joined")
+	}
+	if _, ok := plug.IdleFor(4); ok {
+		t.Error("IdleFor a user that isn't in the room")
+	}
+
+	handleAction_vote(plug, json.RawMessage(`{"i":2,"v":-1}`))
+	payload := nextPayload(t, votes).(VotePayload)
+	if payload.User == nil || payload.User.ID != 2 || payload.Vote != -1 {
+		t.Errorf("vote = %+v, want a meh from alice", payload)
+	}
+	if activity, _ := plug.Activity(2); activity.LastVote.IsZero() || !activity.LastChat.IsZero() {
+		t.Errorf("activity = %+v, want only a vote", activity)
+	}
+
+	handleAction_userJoin(plug, json.RawMessage(`{"id":4,"username":"carol"}`))
+	if _, ok := plug.Activity(4); !ok {
+		t.Error("no activity for a user that joined")
+	}
+
+	handleAction_userLeave(plug, json.RawMessage(`4`))
+	if _, ok := plug.Activity(4); ok {
+		t.Error("still have activity for a user that left")
+	}
+}
+
+func TestIdleDJs(t *testing.T) {
+	plug := newPlaybackPlug(Config{})
+	plug.Room.Booth.WaitingDJs = []int{3, 2}
+	now := time.Now()
+	plug.activity = map[int]*Activity{
+		2: {Joined: now.Add(-time.Hour), LastChat: now.Add(-time.Minute)},
+		3: {Joined: now.Add(-time.Hour), LastVote: now.Add(-30 * time.Minute)},
+	}
+
+	idle := plug.IdleDJs(10 * time.Minute)
+	if len(idle) != 1 || idle[0].ID != 3 {
+		t.Errorf("IdleDJs = %+v, want only bob", idle)
+	}
+	if pos := plug.Room.waitlistPosition(3); pos != 0 {
+		t.Errorf("bob is at %d, want 0", pos)
+	}
+	if pos := plug.Room.waitlistPosition(4); pos != -1 {
+		t.Errorf("nobody is at %d, want -1", pos)
+	}
+
+	// being active again means they aren't idle
+	plug.updateActivity(3, func(activity *Activity) { activity.LastChat = time.Now() })
+	if idle := plug.IdleDJs(10 * time.Minute); len(idle) != 0 {
+		t.Errorf("IdleDJs = %+v, want nobody", idle)
+	}
+}
@@ -6,37 +6,102 @@ import (
 	// "crypto/sha512"
 	"github.com/pkg/errors"
 	// "encoding/hex"
-	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/websocket"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // PlugDJ is the individual user connected to plug
 type PlugDJ struct {
 	config  *Config
 	Room    *Room
-	History []HistoryItem
-	User    *User
+	History []HistoryItem // Most recent first, use QueryHistory to read safely
 	Log     *log.Logger
 
-	web                 *http.Client
-	wss                 *websocket.Conn
-	authCode            string
-	currentlyConnecting bool
-	location            *time.Location
-
-	closer chan struct{}
-
-	// only used once in sockets.go to determine
-	// whether WS server authentication succeeded
-	ack chan error
+	// User is who we are logged in as, along with our role in the room.
+	// It is replaced rather than changed whenever our profile changes.
+	//
+	// Deprecated: reading User while it is being replaced is a data race,
+	// use Me instead.
+	User *User
+
+	web      *http.Client
+	wss      *websocket.Conn // guarded by writeLock
+	authCode string
+	clock    *ServerClock
+
+	// non-zero while we are connecting to a room, so that only
+	// one connection attempt runs at once (see startConnecting)
+	connecting int32
+
+	// closer of the current connection, and the channel that
+	// sockets.go uses to find out whether WS server authentication
+	// succeeded. Both are replaced whenever we reconnect.
+	closer   chan struct{}
+	ack      chan error
+	connLock sync.Mutex
 
 	// for events registered
 	eventFuncs map[Event]ProcessPayloadFunc
+
+	// set by NewReplay, handlers are then run one at a
+	// time and in order instead of in their own goroutines
+	replaying bool
+
+	// our own messages waiting to be seen
+	// in chat so that they can be deleted
+	pendingDeletes []pendingDelete
+	pendingLock    sync.Mutex
+
+	// the full profile of the logged in user,
+	// plug.User is a copy of it
+	me     *Profile
+	meLock sync.RWMutex
+
+	state     State
+	left      bool // did we leave our room with LeaveRoom
+	stateLock sync.RWMutex
+
+	endpointLimiters map[string]*limiter
+
+	stats     Stats
+	statsLock sync.RWMutex
+
+	// guards wss, only one goroutine may write to the socket at once
+	writeLock sync.Mutex
+
+	// timers for the TrackEndingEvent and StuckTrackEvent
+	trackTimers []*time.Timer
+	trackLock   sync.Mutex
+
+	historyFetched bool // have we had History from plug in this room
+	historyLock    sync.RWMutex
+
+	// what the users in the room have been up to
+	activity     map[int]*Activity
+	activityLock sync.RWMutex
+
+	// strikes of users breaking Config.ChatModeration
+	strikes    map[int]*strike
+	strikeLock sync.Mutex
+
+	// waitlist spots of users that left, see waitlist.go
+	spots    map[int]savedSpot
+	vanished map[int]savedSpot
+	spotLock sync.Mutex
+
+	// users looked up with GetUser(s), and users that left the room
+	users    *userCache
+	departed *userCache
+
+	// so that we only log in again once at a time
+	authLock sync.Mutex
+	authTime time.Time
 }
 
 // Config is the configuration for logging into plug
@@ -46,6 +111,115 @@ type Config struct {
 	BaseURL   string
 	SocketURL string
 	Log       *log.Logger
+
+	// Transport is used for all REST requests,
+	// http.DefaultTransport is used if nil
+	Transport http.RoundTripper
+
+	// ReplyDeleteAfter makes Reply and Mention delete
+	// their messages after this long. Zero keeps them.
+	ReplyDeleteAfter time.Duration
+
+	// Reconnect decides how we try to get our socket back when it
+	// drops unexpectedly. The zero value never tries to reconnect,
+	// unless plug stopped sending heartbeats: then we try five times,
+	// waiting a second at first and doubling that up to 30 seconds.
+	Reconnect RetryPolicy
+
+	// MaintenanceRetry decides how we try to reconnect after plug has
+	// gone down for maintenance. The zero value never tries to reconnect.
+	MaintenanceRetry RetryPolicy
+
+	// Retry decides how failed REST requests are retried: rate limited
+	// ones always, idempotent ones also after network or server errors.
+	// The zero value only retries rate limited requests, three times.
+	// Expired sessions are always renewed.
+	Retry RetryPolicy
+
+	// RateLimit limits all REST requests and chat messages together,
+	// EndpointLimits limits requests to endpoints starting with the key.
+	RateLimit      RateLimit
+	EndpointLimits map[string]RateLimit
+
+	// Trace logs every REST request and response and every socket
+	// frame at TraceLevel (DebugLevel if zero). Passwords, csrf tokens
+	// and socket auth codes are redacted, but chat messages are not.
+	Trace      bool
+	TraceLevel log.Level
+
+	// If plug sends nothing (not even a heartbeat) for HeartbeatTimeout,
+	// the socket is considered dead and we reconnect according to
+	// Reconnect (see there for when it is zero).
+	// Zero means a minute, a negative value never times out.
+	HeartbeatTimeout time.Duration
+
+	// How often we ping the socket to measure our latency.
+	// Zero means every 30 seconds, a negative value never pings.
+	PingInterval time.Duration
+
+	// A TrackEndingEvent is sent this long before each play ends.
+	// Zero means it is never sent.
+	TrackEndingNotice time.Duration
+
+	// A StuckTrackEvent is sent when a play goes on this long past the
+	// length of its media. Zero means 10 seconds, a negative value never.
+	StuckTrackGrace time.Duration
+
+	// How many plays are kept in History as the room advances.
+	// Zero means 50, which is also how many plug gives us.
+	HistorySize int
+
+	// Users looked up by ID are cached, so that they don't have to be
+	// looked up again. Zero means 500 users, each kept for an hour.
+	// A negative size disables the cache, a negative TTL never expires.
+	UserCacheSize int
+	UserCacheTTL  time.Duration
+
+	// Users that leave the room are remembered, so that chat and
+	// moderation about them can still tell who they were. Zero means
+	// 200 users, each kept for half an hour. Negatives work as above.
+	DepartedCacheSize int
+	DepartedCacheTTL  time.Duration
+
+	// IdleTimeout is how long someone in the waitlist may go without
+	// chatting or voting before a UserIdleEvent is sent. Zero means never.
+	IdleTimeout time.Duration
+
+	// MediaPolicies are checked in order on every advance, and the
+	// first one the play breaks is acted upon. Skipping and moving
+	// DJs needs us to be at least a bouncer in the room.
+	MediaPolicies []MediaPolicy
+
+	// WaitlistGrace is how long the waitlist spot of someone that leaves
+	// the room is kept. If they come back in time they are put back where
+	// they were, which needs us to be at least a bouncer. Zero means never.
+	WaitlistGrace time.Duration
+
+	// SpotCommand is a chat command (e.g. "!spot") that tells whoever
+	// uses it where they are in the waitlist. Spots are restored as soon
+	// as their owner rejoins, so a saved spot is only mentioned if we
+	// couldn't put them back (e.g. we aren't staff). Empty means no command.
+	SpotCommand string
+
+	// ChatModeration deletes messages that break its rules, muting and
+	// banning those that keep breaking them. It needs us to be at least
+	// a bouncer in the room. Nil means chat isn't moderated.
+	ChatModeration *ChatModeration
+
+	// AuditLog is given every moderation action we take, and every
+	// one plug tells us others took. Our own are recorded as we take
+	// them, not again when plug tells us. Nil means nothing is recorded.
+	AuditLog AuditLog
+
+	// Recorder records every socket frame so it can be replayed later
+	Recorder *Recorder
+
+	// from RateLimit, or shared between sessions by a Manager
+	limiter *limiter
+
+	// called with every event emitted, set by a Manager before
+	// New so that events sent while logging in aren't missed
+	eventHook func(plug *PlugDJ, event Event, payload interface{})
 }
 
 // New returns an authenticated User
@@ -69,15 +243,43 @@ func New(config Config) (*PlugDJ, error) {
 		return nil, errors.New("plugapi: invalid url provided")
 	}
 
+	plug := newPlug(config)
+
+	if err := plug.authenticateUser(); err != nil {
+		return nil, err
+	}
+	plug.authTime = time.Now()
+
+	plug.Log.Info("Running go-plugapi")
+	return plug, nil
+}
+
+// newPlug sets up everything New needs without talking to plug
+func newPlug(config Config) *PlugDJ {
+	if config.limiter == nil {
+		config.limiter = newLimiter(config.RateLimit)
+	}
+
 	plug := &PlugDJ{
 		config:     &config,
 		Log:        config.Log,
 		eventFuncs: make(map[Event]ProcessPayloadFunc),
-		Room:       &Room{},
+		clock:      &ServerClock{},
+
+		endpointLimiters: make(map[string]*limiter),
+		activity:         make(map[int]*Activity),
+		spots:            make(map[int]savedSpot),
+		strikes:          make(map[int]*strike),
+		vanished:         make(map[int]savedSpot),
+	}
+
+	for prefix, limit := range config.EndpointLimits {
+		plug.endpointLimiters[prefix] = newLimiter(limit)
 	}
 
-	// a closer so that we can close any goroutines we have created
-	plug.closer = make(chan struct{})
+	plug.users = newCache(config.UserCacheSize, config.UserCacheTTL, defaultUserCacheSize, defaultUserCacheTTL)
+	plug.departed = newCache(config.DepartedCacheSize, config.DepartedCacheTTL, defaultDepartedCacheSize, defaultDepartedCacheTTL)
+	plug.Room = plug.newRoom()
 
 	// was this just used to uniquely get a fucking jar?!
 	// hash := sha512.Sum512([]byte(config.Email + config.Password))
@@ -89,70 +291,92 @@ func New(config Config) (*PlugDJ, error) {
 	cookieJar, _ := cookiejar.New(&opts)
 
 	// create our web client so that we can make REST requests
-	plug.web = &http.Client{Jar: cookieJar}
-
-	if err := plug.authenticateUser(); err != nil {
-		return nil, err
+	plug.web = &http.Client{Jar: cookieJar, Transport: config.Transport}
+	if config.Trace {
+		plug.web.Transport = &tracingTransport{plug, config.Transport}
 	}
 
-	plug.Log.Info("Running go-plugapi")
-	return plug, nil
+	return plug
 }
 
 func (plug *PlugDJ) Close() {
 	plug.Log.Debugln("plugapi will now close")
+	plug.setState(ClosedState)
+	plug.scheduleTrackTimers(nil)
+	plug.closeSocket()
+}
 
-	if plug.wss != nil {
+// closeSocket cleanly closes our socket if we have one.
+// The state should be changed first so that we don't reconnect.
+func (plug *PlugDJ) closeSocket() {
+	closer, _ := plug.connection()
+
+	// take the socket so that nothing else can write to it,
+	// and send the close frame while nothing else can
+	plug.writeLock.Lock()
+	wss := plug.wss
+	plug.wss = nil
+	var err error
+	if wss != nil {
 		// To cleanly close a connection, a client should send a close
 		// frame and wait for the server to close the connection.
-		err := plug.wss.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-		if err != nil {
-			plug.Log.Println("write close:", err)
-			return
-		}
+		err = wss.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}
+	plug.writeLock.Unlock()
 
-		select {
-		// Our socket ticker will receive an error
-		// the ticker will write to plug.closer when the
-		// error is a "CloseNormalClosure" error
-		case <-plug.closer:
-			plug.Log.Debugln("sockets closed successfully")
-		// As a backup, we wait a second instead.
-		case <-time.After(time.Second):
-			plug.Log.Warnln("sockets took too long to close")
-		}
+	if wss == nil {
+		return
+	}
 
-		// Now we close our clientside connection
-		plug.wss.Close()
+	if err != nil {
+		plug.Log.Println("write close:", err)
+		wss.Close()
+		return
 	}
-}
 
-func (plug *PlugDJ) JoinRoom(slug string) error {
-	// prevent multiple simultaneous connections
-	if plug.currentlyConnecting {
-		return errors.New("plugapi: already connecting to a room")
+	select {
+	// Our socket ticker will receive an error
+	// the ticker will write to plug.closer when the
+	// error is a "CloseNormalClosure" error
+	case <-closer:
+		plug.Log.Debugln("sockets closed successfully")
+	// As a backup, we wait a second instead.
+	case <-time.After(time.Second):
+		plug.Log.Warnln("sockets took too long to close")
 	}
 
-	// get our list of cookies so that we can check if we are logged in
-	url, _ := url.Parse(plug.config.BaseURL) // we ignore parse errors...
-	cookies := plug.web.Jar.Cookies(url)
-	sessionSet := false
+	// Now we close our clientside connection
+	wss.Close()
+}
 
-	// iterate through and check for a "session" cookie
-	for _, cookie := range cookies {
-		sessionSet = cookie.Name == "session"
-		if sessionSet {
-			break
-		}
+// JoinRoom connects to plug and joins the room. If we
+// are already in a room, we switch to this one instead.
+func (plug *PlugDJ) JoinRoom(slug string) error {
+	if plug.State() == ConnectedState {
+		return plug.SwitchRoom(slug)
 	}
 
 	// tell them they are not logged in
-	if !sessionSet {
+	if !plug.loggedIn() {
 		// plugapi waits "a frame" to try again, we won't do this.
 		return errors.New("plugapi: not logged in")
 	}
 
-	plug.currentlyConnecting = true
+	// prevent multiple simultaneous connections
+	if !plug.startConnecting() {
+		return errors.New("plugapi: already connecting to a room")
+	}
+	plug.setState(ConnectingState)
+
+	// make sure we can try again if anything goes wrong,
+	// without a socket left over from this attempt
+	defer func() {
+		plug.doneConnecting()
+		if plug.State() == ConnectingState {
+			plug.setState(DisconnectedState)
+			plug.closeSocket()
+		}
+	}()
 
 	// NOTE: Reference > queueConnectSocket(roomSlug) < is now called
 	// This tells the queue to call > connectSocket(roomSlug) <
@@ -162,30 +386,29 @@ func (plug *PlugDJ) JoinRoom(slug string) error {
 		return errors.Wrap(err, "could not connect to socket server")
 	}
 
-	selfInfo := []struct {
-		ID       int    `json:"id"`
-		Username string `json:"username"`
-	}{}
-
-	if err := plug.GetData(UserInfoEndpoint, &selfInfo, nil); err != nil {
+	if _, err := plug.RefreshMe(); err != nil {
 		return err
 	}
 
-	plug.User = &User{
-		ID:       selfInfo[0].ID,
-		Username: selfInfo[0].Username,
+	if err := plug.joinRoom(slug); err != nil {
+		return err
 	}
 
+	plug.setLeft(false)
+	plug.setState(ConnectedState)
+	return nil
+}
+
+// joinRoom joins the room over REST and loads its state.
+// Our socket must already be connected for this to work.
+func (plug *PlugDJ) joinRoom(slug string) error {
 	// TODO: Should this be queued?
 	plug.Log.Debugln("Joining room...")
-	resp, err := plug.Post(RoomJoinEndpoint, map[string]string{"slug": slug})
-	if err != nil {
-		return errors.Wrap(err, "could not join room")
-	}
-
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+	err := plug.PostData(RoomJoinEndpoint, map[string]string{"slug": slug}, nil, nil)
+	if e, ok := asAPIError(err); ok && e.StatusCode >= 400 && e.StatusCode < 500 {
 		return errors.New("plugapi: invalid room url")
+	} else if err != nil {
+		return errors.Wrap(err, "could not join room")
 	}
 
 	// Now we need to load ALL information about our current room state
@@ -200,37 +423,37 @@ func (plug *PlugDJ) JoinRoom(slug string) error {
 		panic("should terminate above")
 	}
 
-	// Now we're sure the room exists, store it
-	// locally because we need it later on here
-	room := data[0].Room
-	room.SetUsers(data[0].Users)
-	users := room.GetUsers()
-	fmt.Printf("\n\n\n\nRoom data\n\n\n\n%+v\n\n\n\n\n%+v", room, users)
-
-	// add the room to our obj
-	plug.Room = room
+	// Now we're sure the room exists, store it. plug.Room is
+	// used without any locking, so it is updated in place.
+	room := plug.Room
+	room.replace(data[0].room(), data[0].Users)
+	plug.resetActivity(data[0].Users)
+	plug.Log.WithFields(log.Fields{
+		"slug":  data[0].Meta.Slug,
+		"users": len(data[0].Users),
+	}).Debugln("Loaded room state")
 
 	// and the now add the user's role
-	plug.User.Role = data[0].Role
+	plug.updateMe(func(me *Profile) { me.Role = data[0].Role })
+
+	plug.recordState(data[0])
 
 	// Now we need to emit an AdvanceEvent
 	plug.emitEvent(AdvanceEvent, AdvancePayload{
 		CurrentDJ: room.getDJ(),
 		DJs:       room.getDJs(),
 		LastPlay:  nil,
-		Playback:  room.Playback,
+		Playback:  data[0].Playback,
 	})
+	plug.scheduleTrackTimers(data[0].Playback)
 
 	// Retrieve our history
-	err = plug.GetData(HistoryEndpoint, &plug.History, nil)
-	if err != nil {
+	if err := plug.RefreshHistory(); err != nil {
 		return err
 	}
 
 	// Now we need to emit a RoomJoinEvent
-	plug.emitEvent(RoomJoinEvent, room.Meta.Name)
-
-	plug.currentlyConnecting = false
+	plug.emitEvent(RoomJoinEvent, data[0].Meta.Name)
 
 	return nil
 }
@@ -240,7 +463,7 @@ func (plug *PlugDJ) SendChat(msg string) error {
 		return errors.New("go-plugapi: message is empty")
 	}
 
-	if len(msg) > 250 {
+	if utf8.RuneCountInString(msg) > maxChatLength {
 		return errors.New("go-plugapi: message is too long")
 	}
 
@@ -248,8 +471,8 @@ func (plug *PlugDJ) SendChat(msg string) error {
 	// The goroutine will use a linear backoff
 	// to prevent too many messages from being sent
 	// (plug.dj may ban or disconnect you for spam)
-	plug.sendSocketJSON("chat", msg)
-	return nil
+	plug.config.limiter.wait()
+	return plug.sendSocketJSON("chat", msg)
 }
 
 // RegisterEvents registers the function to call when the specified event(s) are encountered
@@ -259,25 +482,3 @@ func (plug *PlugDJ) RegisterEvents(fn ProcessPayloadFunc, events ...Event) {
 		plug.eventFuncs[event] = fn
 	}
 }
-
-func (plug *PlugDJ) ModerateDeleteMessage(messageID string) error {
-	req, err := http.NewRequest("DELETE", plug.getAPIURL()+ChatDeleteEndpoint+messageID, nil)
-	if err != nil {
-		return nil
-	}
-
-	resp, err := plug.web.Do(req)
-	if err != nil {
-		return err
-	}
-
-	var errs []string
-	if err := handleResponse(resp, &errs, nil); err != nil {
-		plug.Log.WithFields(log.Fields{"data": errs, "error": err}).Warnln("plugapi: could not delete chat message")
-		return err
-	}
-
-	quickread(resp.Body)
-	resp.Body.Close()
-	return nil
-}
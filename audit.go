@@ -0,0 +1,297 @@
+package plugapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Moderation actions, as in AuditEntry.Action
+const (
+	AuditAddDJ      = "addDJ"
+	AuditBan        = "ban"
+	AuditDeleteChat = "deleteChat"
+	AuditMoveDJ     = "moveDJ"
+	AuditMute       = "mute"
+	AuditRemoveDJ   = "removeDJ"
+	AuditSkip       = "skip"
+	AuditStaff      = "staff"
+	AuditUnmute     = "unmute"
+)
+
+// AuditUser is who did or had something done to them. Either
+// field may be missing if plug didn't tell us or we don't know.
+type AuditUser struct {
+	ID       int    `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// AuditEntry is a single moderation action, by us or anyone else
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Room     string    `json:"room"` // slug of the room it happened in
+	Action   string    `json:"action"`
+	Actor    AuditUser `json:"actor"`
+	Target   AuditUser `json:"target"`             // empty for deleted chat from unknown users
+	Duration string    `json:"duration,omitempty"` // of mutes and bans, e.g. "15m0s" or "forever"
+	Reason   string    `json:"reason,omitempty"`
+	Detail   string    `json:"detail,omitempty"` // e.g. the deleted message ID or the new waitlist position
+}
+
+// AuditLog keeps moderation actions, see Config.AuditLog
+type AuditLog interface {
+	// Append adds an entry to the log
+	Append(entry AuditEntry) error
+
+	// Query returns the entries matching the query, the most recent first
+	Query(q AuditQuery) ([]AuditEntry, error)
+}
+
+// AuditQuery filters an AuditLog. Zero values don't filter.
+type AuditQuery struct {
+	Action   string    // only this action, e.g. AuditBan
+	ActorID  int       // only actions by this user
+	TargetID int       // only actions against this user
+	Since    time.Time // only actions at or after this
+	Until    time.Time // only actions before this
+
+	Limit int // return at most this many entries
+}
+
+func (q AuditQuery) matches(entry AuditEntry) bool {
+	switch {
+	case q.Action != "" && entry.Action != q.Action:
+		return false
+	case q.ActorID != 0 && entry.Actor.ID != q.ActorID:
+		return false
+	case q.TargetID != 0 && entry.Target.ID != q.TargetID:
+		return false
+	case !q.Since.IsZero() && entry.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !entry.Time.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// filter takes entries oldest first, returning the
+// ones matching the query the most recent first
+func (q AuditQuery) filter(entries []AuditEntry) []AuditEntry {
+	var results []AuditEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !q.matches(entries[i]) {
+			continue
+		}
+
+		results = append(results, entries[i])
+		if q.Limit > 0 && len(results) == q.Limit {
+			break
+		}
+	}
+	return results
+}
+
+// MemoryAuditLog keeps the most recent entries in memory
+type MemoryAuditLog struct {
+	sync.RWMutex
+	entries []AuditEntry // oldest first
+	size    int
+}
+
+// NewMemoryAuditLog keeps at most size entries, zero means all of them
+func NewMemoryAuditLog(size int) *MemoryAuditLog {
+	return &MemoryAuditLog{size: size}
+}
+
+// Append adds an entry, forgetting the oldest if the log is full
+func (l *MemoryAuditLog) Append(entry AuditEntry) error {
+	l.Lock()
+	defer l.Unlock()
+
+	l.entries = append(l.entries, entry)
+	if l.size > 0 && len(l.entries) > l.size {
+		l.entries = append([]AuditEntry(nil), l.entries[len(l.entries)-l.size:]...)
+	}
+	return nil
+}
+
+// Query returns the entries matching the query, the most recent first
+func (l *MemoryAuditLog) Query(q AuditQuery) ([]AuditEntry, error) {
+	l.RLock()
+	defer l.RUnlock()
+
+	return q.filter(l.entries), nil
+}
+
+// FileAuditLog appends entries to a JSONL file (one AuditEntry per line)
+type FileAuditLog struct {
+	sync.Mutex
+	path string
+	f    *os.File
+}
+
+// NewFileAuditLog creates (or appends to) the file at path
+func NewFileAuditLog(path string) (*FileAuditLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileAuditLog{path: path, f: f}, nil
+}
+
+// Append writes an entry to the end of the file
+func (l *FileAuditLog) Append(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	_, err = l.f.Write(append(line, '\n'))
+	return err
+}
+
+// Query reads the whole file, returning the entries
+// matching the query, the most recent first
+func (l *FileAuditLog) Query(q AuditQuery) ([]AuditEntry, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+
+		if q.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return q.filter(entries), nil
+}
+
+// Close closes the file
+func (l *FileAuditLog) Close() error {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.f.Close()
+}
+
+// QueryAudit queries Config.AuditLog, returning nothing if there isn't one
+func (plug *PlugDJ) QueryAudit(q AuditQuery) ([]AuditEntry, error) {
+	if plug.config.AuditLog == nil {
+		return nil, nil
+	}
+	return plug.config.AuditLog.Query(q)
+}
+
+// audit appends the entry to Config.AuditLog, if there is one
+func (plug *PlugDJ) audit(entry AuditEntry) {
+	if plug.config.AuditLog == nil {
+		return
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = plug.ServerNow()
+	}
+
+	plug.Room.RLock()
+	entry.Room = plug.Room.Meta.Slug
+	plug.Room.RUnlock()
+
+	if err := plug.config.AuditLog.Append(entry); err != nil {
+		plug.Log.WithField("error", err).Warnln("could not append to audit log")
+	}
+}
+
+// auditSelf audits an action we took ourselves
+func (plug *PlugDJ) auditSelf(action string, targetID int, entry AuditEntry) {
+	me := plug.Me()
+	entry.Action = action
+	entry.Actor = AuditUser{me.ID, me.Username}
+	if targetID > 0 {
+		entry.Target = plug.auditUser(targetID, "")
+	}
+	plug.audit(entry)
+}
+
+// auditUser fills in whatever we know about the user
+func (plug *PlugDJ) auditUser(id int, username string) AuditUser {
+	if id > 0 && username == "" {
+		if user := plug.Room.getUser(id); user != nil {
+			username = user.Username
+		}
+	} else if id == 0 && username != "" {
+		if user := plug.Room.getUserByName(username); user != nil {
+			id = user.ID
+		}
+	}
+	return AuditUser{id, username}
+}
+
+// Lengths of mutes and bans, for AuditEntry.Duration
+var (
+	muteLengths = map[MuteDuration]string{
+		MuteShort:  (15 * time.Minute).String(),
+		MuteMedium: (30 * time.Minute).String(),
+		MuteLong:   (45 * time.Minute).String(),
+	}
+	banLengths = map[BanDuration]string{
+		BanHour:    time.Hour.String(),
+		BanDay:     (24 * time.Hour).String(),
+		BanForever: "forever",
+	}
+)
+
+// plug's reason codes, for AuditEntry.Reason
+var (
+	muteReasons = map[int]string{
+		1: "Violating community rules",
+		2: "Verbal abuse or harassment",
+		3: "Spamming or trolling",
+		4: "Offensive language",
+		5: "Negative attitude",
+	}
+	banReasons = map[int]string{
+		1: "Spamming or trolling",
+		2: "Verbal abuse or offensive language",
+		3: "Playing offensive videos/songs",
+		4: "Repeatedly playing inappropriate genre(s)",
+		5: "Negative attitude",
+	}
+)
+
+// reasonText turns a reason code into what it means
+func reasonText(reasons map[int]string, code int) string {
+	if text, ok := reasons[code]; ok {
+		return text
+	}
+	if code == 0 {
+		return ""
+	}
+	return strconv.Itoa(code)
+}
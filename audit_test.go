@@ -0,0 +1,204 @@
+package plugapi
+
+import (
+	"encoding/json"
+	log "github.com/Sirupsen/logrus"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// auditEntries makes one entry per action, a minute apart and oldest first
+func auditEntries(start time.Time, actions ...string) []AuditEntry {
+	var entries []AuditEntry
+	for i, action := range actions {
+		entries = append(entries, AuditEntry{
+			Time:   start.Add(time.Duration(i) * time.Minute),
+			Action: action,
+			Actor:  AuditUser{ID: 10 + i%2},
+			Target: AuditUser{ID: 20 + i},
+		})
+	}
+	return entries
+}
+
+func TestAuditQueryFilter(t *testing.T) {
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	entries := auditEntries(start, AuditSkip, AuditBan, AuditMute, AuditBan, AuditSkip)
+
+	tests := []struct {
+		name  string
+		query AuditQuery
+		want  []int // target IDs, the most recent first
+	}{
+		{"everything", AuditQuery{}, []int{24, 23, 22, 21, 20}},
+		{"action", AuditQuery{Action: AuditBan}, []int{23, 21}},
+		{"actor", AuditQuery{ActorID: 10}, []int{24, 22, 20}},
+		{"target", AuditQuery{TargetID: 22}, []int{22}},
+		{"since is inclusive", AuditQuery{Since: start.Add(3 * time.Minute)}, []int{24, 23}},
+		{"until is exclusive", AuditQuery{Until: start.Add(2 * time.Minute)}, []int{21, 20}},
+		{"limit keeps the most recent", AuditQuery{Limit: 2}, []int{24, 23}},
+		{"combined", AuditQuery{Action: AuditSkip, ActorID: 10, Limit: 1}, []int{24}},
+		{"nothing", AuditQuery{Action: AuditStaff}, nil},
+	}
+
+	for _, test := range tests {
+		var got []int
+		for _, entry := range test.query.filter(entries) {
+			got = append(got, entry.Target.ID)
+		}
+
+		if !equalInts(got, test.want) {
+			t.Errorf("%s: got targets %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryAuditLog(t *testing.T) {
+	l := NewMemoryAuditLog(3)
+	for _, entry := range auditEntries(time.Now(), AuditSkip, AuditBan, AuditMute, AuditBan) {
+		l.Append(entry)
+	}
+
+	entries, _ := l.Query(AuditQuery{})
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want the 3 most recent", len(entries))
+	}
+	if entries[0].Target.ID != 23 || entries[2].Target.ID != 21 {
+		t.Errorf("got %+v, want the most recent first", entries)
+	}
+}
+
+func TestFileAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	l, err := NewFileAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range auditEntries(start, AuditSkip, AuditBan) {
+		if err := l.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	// opening it again appends
+	l, err = NewFileAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Append(AuditEntry{Time: start.Add(time.Hour), Action: AuditBan, Target: AuditUser{ID: 99}})
+
+	entries, err := l.Query(AuditQuery{Action: AuditBan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Target.ID != 99 || entries[1].Target.ID != 21 {
+		t.Errorf("got %+v, want both bans, the most recent first", entries)
+	}
+	if !entries[1].Time.Equal(start.Add(time.Minute)) {
+		t.Errorf("time = %v, want %v", entries[1].Time, start.Add(time.Minute))
+	}
+}
+
+func TestModerationIsAudited(t *testing.T) {
+	audit := NewMemoryAuditLog(0)
+	plug := NewReplay(Config{Log: log.New(), AuditLog: audit})
+	plug.me = &Profile{User: User{ID: 1, Username: "bot"}}
+	plug.Room.SetUsers([]User{{ID: 2, Username: "mod"}, {ID: 3, Username: "alice"}})
+
+	frames := []struct {
+		handler func(*PlugDJ, json.RawMessage)
+		data    string
+	}{
+		{handleAction_modBan, `{"m":"mod","mi":2,"t":"alice","i":3,"d":"h","r":1}`},
+		{handleAction_modMute, `{"m":"mod","mi":2,"t":"alice","i":3,"d":"s","r":3}`},
+		{handleAction_modMute, `{"m":"mod","mi":2,"t":"alice","i":3,"d":"o"}`},
+		{handleAction_modMoveDJ, `{"m":"mod","mi":2,"u":"alice","o":3,"n":1}`},
+
+		// we can't know who was skipped
+		{handleAction_modSkip, `{"m":"mod","mi":2}`},
+
+		// our own actions were audited when we took them
+		{handleAction_modSkip, `{"m":"bot","mi":1}`},
+
+		// nor are users deleting their own messages
+		{handleAction_chatDelete, `{"c":"3-abc"}`},
+	}
+	for _, f := range frames {
+		f.handler(plug, json.RawMessage(f.data))
+	}
+
+	entries, _ := plug.QueryAudit(AuditQuery{})
+	want := []AuditEntry{
+		{Action: AuditSkip, Actor: AuditUser{2, "mod"}},
+		{Action: AuditMoveDJ, Actor: AuditUser{2, "mod"}, Target: AuditUser{3, "alice"}, Detail: "from 3 to 1"},
+		{Action: AuditUnmute, Actor: AuditUser{2, "mod"}, Target: AuditUser{3, "alice"}},
+		{Action: AuditMute, Actor: AuditUser{2, "mod"}, Target: AuditUser{3, "alice"}, Duration: "15m0s", Reason: "Spamming or trolling"},
+		{Action: AuditBan, Actor: AuditUser{2, "mod"}, Target: AuditUser{3, "alice"}, Duration: "1h0m0s", Reason: "Spamming or trolling"},
+	}
+
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries %+v, want %d", len(entries), entries, len(want))
+	}
+	for i, entry := range entries {
+		if entry.Time.IsZero() {
+			t.Errorf("entry %d has no time", i)
+		}
+		entry.Time = time.Time{}
+		if entry != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want[i])
+		}
+	}
+}
+
+func TestOwnModerationIsAudited(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		writeEnvelope(w, "ok", []struct{}{})
+	})
+	plug.config.AuditLog = NewMemoryAuditLog(0)
+	plug.clock = &ServerClock{}
+	plug.me = &Profile{User: User{ID: 1, Username: "bot"}}
+	plug.Room = &Room{}
+	plug.Room.SetUsers([]User{{ID: 3, Username: "alice"}})
+
+	alice := &User{ID: 3, Username: "alice"}
+	plug.applyChatAction(DeleteChatAction, ChatPayload{MessageID: "3-abc", User: alice}, 3, "it contains a link")
+	plug.applyPolicy(RemoveAction, &Playback{HistoryID: "h"}, alice, "it is too long")
+	plug.ModerateDeleteMessage("3-def")
+
+	entries, _ := plug.QueryAudit(AuditQuery{})
+	bot, target := AuditUser{1, "bot"}, AuditUser{3, "alice"}
+	want := []AuditEntry{
+		{Action: AuditDeleteChat, Actor: bot, Target: target, Detail: "3-def"},
+		{Action: AuditRemoveDJ, Actor: bot, Target: target, Reason: "it is too long"},
+		{Action: AuditSkip, Actor: bot, Target: target, Reason: "it is too long", Detail: "h"},
+		{Action: AuditDeleteChat, Actor: bot, Target: target, Reason: "it contains a link", Detail: "3-abc"},
+	}
+
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries %+v, want %d", len(entries), entries, len(want))
+	}
+	for i, entry := range entries {
+		entry.Time = time.Time{}
+		if entry != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want[i])
+		}
+	}
+}
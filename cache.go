@@ -0,0 +1,101 @@
+package plugapi
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// userCache is a least recently used cache of users, where
+// users are also forgotten once they have been cached too long
+type userCache struct {
+	sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[int]*list.Element
+	order *list.List // most recently used at the front
+}
+
+type cacheEntry struct {
+	user    User
+	expires time.Time
+}
+
+// newUserCache makes a cache holding at most size users
+// for at most ttl each (zero means they don't expire)
+func newUserCache(size int, ttl time.Duration) *userCache {
+	return &userCache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[int]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get returns a copy of the user if it is cached and fresh.
+// A nil cache never has anyone in it.
+func (c *userCache) get(id int) (*User, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	elem, ok := c.items[id]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.items, id)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	user := entry.user
+	return &user, true
+}
+
+// add caches the user, replacing them if they were cached already
+func (c *userCache) add(user User) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	entry := &cacheEntry{user, time.Now().Add(c.ttl)}
+	if elem, ok := c.items[user.ID]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[user.ID] = c.order.PushFront(entry)
+
+	// forget whoever we haven't needed for the longest
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).user.ID)
+	}
+}
+
+// remove forgets the user
+func (c *userCache) remove(id int) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if elem, ok := c.items[id]; ok {
+		c.order.Remove(elem)
+		delete(c.items, id)
+	}
+}
@@ -0,0 +1,195 @@
+package plugapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestUserCache(t *testing.T) {
+	c := newUserCache(2, time.Hour)
+	c.add(User{ID: 1, Username: "one"})
+	c.add(User{ID: 2, Username: "two"})
+
+	// using 1 makes 2 the least recently used
+	if u, ok := c.get(1); !ok || u.Username != "one" {
+		t.Fatalf("get(1) = %v, %t", u, ok)
+	}
+	c.add(User{ID: 3, Username: "three"})
+
+	if _, ok := c.get(2); ok {
+		t.Error("least recently used user wasn't evicted")
+	}
+	if _, ok := c.get(1); !ok {
+		t.Error("recently used user was evicted")
+	}
+
+	// adding again replaces
+	c.add(User{ID: 3, Username: "THREE"})
+	if u, _ := c.get(3); u == nil || u.Username != "THREE" {
+		t.Errorf("get(3) = %v, want the replaced user", u)
+	}
+
+	// copies are handed out
+	u, _ := c.get(3)
+	u.Username = "changed"
+	if u, _ := c.get(3); u.Username != "THREE" {
+		t.Error("changing a user from get changed the cache")
+	}
+
+	c.remove(3)
+	if _, ok := c.get(3); ok {
+		t.Error("removed user is still cached")
+	}
+}
+
+func TestUserCacheExpiry(t *testing.T) {
+	c := newUserCache(10, time.Millisecond)
+	c.add(User{ID: 1})
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.get(1); ok {
+		t.Error("expired user is still cached")
+	}
+
+	// a negative TTL never expires
+	c = newUserCache(10, -1)
+	c.add(User{ID: 1})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.get(1); !ok {
+		t.Error("user expired without a TTL")
+	}
+}
+
+func TestUserCacheDisabled(t *testing.T) {
+	var none *userCache
+	none.add(User{ID: 1})
+	none.remove(1)
+	if _, ok := none.get(1); ok {
+		t.Error("nil cache has a user")
+	}
+
+	c := newUserCache(-1, time.Hour)
+	c.add(User{ID: 1})
+	if _, ok := c.get(1); ok {
+		t.Error("cache with a negative size has a user")
+	}
+}
+
+func TestRoomGetUserFallsBack(t *testing.T) {
+	room := &Room{cache: newUserCache(10, time.Hour)}
+	room.SetUsers([]User{{ID: 1, Username: "here"}})
+	room.cache.add(User{ID: 1, Username: "stale"})
+	room.cache.add(User{ID: 2, Username: "looked up"})
+
+	if u := room.getUser(1); u == nil || u.Username != "here" {
+		t.Errorf("getUser(1) = %v, want the user in the room", u)
+	}
+	if u := room.getUser(2); u == nil || u.Username != "looked up" {
+		t.Errorf("getUser(2) = %v, want the cached user", u)
+	}
+	if u := room.getUser(3); u != nil {
+		t.Errorf("getUser(3) = %v, want nobody", u)
+	}
+}
+
+func TestRoomRemembersDeparted(t *testing.T) {
+	room := &Room{cache: newUserCache(10, time.Hour), departed: newUserCache(10, time.Hour)}
+	room.SetUsers([]User{{ID: 1, Username: "here"}})
+	room.cache.add(User{ID: 2, Username: "looked up"})
+
+	if !room.inRoom(1) {
+		t.Error("user in the room isn't inRoom")
+	}
+	if room.inRoom(2) {
+		t.Error("a cached user is in the room")
+	}
+
+	if u := room.removeUser(1); u == nil || u.Username != "here" {
+		t.Fatalf("removeUser(1) = %v, want the user that left", u)
+	}
+	if room.inRoom(1) {
+		t.Error("user is still in the room after leaving")
+	}
+	if u := room.getUser(1); u == nil || u.Username != "here" {
+		t.Errorf("getUser(1) = %v, want the departed user", u)
+	}
+
+	// leaving twice finds nobody to remove
+	if u := room.removeUser(1); u != nil {
+		t.Errorf("removeUser(1) again = %v, want nil", u)
+	}
+
+	// coming back means they haven't departed any more
+	room.addUser(User{ID: 1, Username: "back"})
+	if _, ok := room.departed.get(1); ok {
+		t.Error("user is departed after coming back")
+	}
+	if u := room.getUser(1); u == nil || u.Username != "back" {
+		t.Errorf("getUser(1) = %v, want the user back in the room", u)
+	}
+	if n := len(room.GetUsers()); n != 1 {
+		t.Errorf("room has %d users, want 1", n)
+	}
+}
+
+func TestGetUsers(t *testing.T) {
+	var asked [][]int
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ IDs []int }
+		json.NewDecoder(r.Body).Decode(&body)
+		asked = append(asked, body.IDs)
+
+		var users []User
+		for _, id := range body.IDs {
+			if id != 404 {
+				users = append(users, User{ID: id, Username: "user" + strconv.Itoa(id)})
+			}
+		}
+		writeEnvelope(w, "ok", users)
+	})
+	plug.users = newUserCache(10, time.Hour)
+	plug.users.add(User{ID: 1, Username: "cached"})
+
+	users, err := plug.GetUsers([]int{1, 2, 404})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Username != "cached" || users[1].ID != 2 {
+		t.Errorf("users = %+v, want the cached user and user 2", users)
+	}
+	if len(asked) != 1 || len(asked[0]) != 2 {
+		t.Errorf("asked for %v, want only the users that weren't cached", asked)
+	}
+
+	// now that they are cached, plug isn't asked again
+	if user, err := plug.GetUser(2); err != nil || user.Username != "user2" {
+		t.Errorf("GetUser(2) = %v, %v, want the cached user", user, err)
+	}
+	if len(asked) != 1 {
+		t.Errorf("asked plug %d times, want once", len(asked))
+	}
+}
+
+func TestGetUserHistory(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_"+HistoryEndpoint {
+			t.Errorf("path = %s, want %s", r.URL.Path, "/_"+HistoryEndpoint)
+		}
+		writeEnvelope(w, "ok", historyItems(6))
+	})
+
+	items, err := plug.GetUserHistory(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := joinIDs(historyIDs(items)); got != "5 3 1" {
+		t.Errorf("history = %s, want bob's plays 5 3 1", got)
+	}
+
+	if _, err := plug.GetUserHistory(0); err == nil {
+		t.Error("GetUserHistory(0) succeeded")
+	}
+}
@@ -0,0 +1,414 @@
+package plugapi
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type mentionType int
+
+// Special mentions that address a group of users instead of a single one
+const (
+	EveryoneMention mentionType = iota // @everyone
+	DJsMention                         // @djs
+	StaffMention                       // @staff
+)
+
+// Map of all special mentions we can detect
+// - map key is the lowercase word following the "@"
+// - value is the mentionType it represents
+var specialMentions = map[string]mentionType{
+	"everyone": EveryoneMention,
+	"djs":      DJsMention,
+	"staff":    StaffMention,
+}
+
+var (
+	// :emoji: style tokens. plug only uses lowercase names,
+	// digits, underscores, pluses and dashes for these.
+	emotePattern = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+
+	// anything that looks like a link. we are deliberately
+	// generous here, plug itself linkifies these the same way
+	linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+)
+
+// parsedChat is the result of parsing the
+// raw text of a chat message
+type parsedChat struct {
+	message  string
+	emote    bool
+	mentions []*User
+	specials []mentionType
+	emotes   []string
+	links    []string
+}
+
+// parseChat unescapes the message, strips any emote prefix and
+// collects all the mentions, emotes and links found within it.
+// Mentions are resolved against the users currently in the room and self.
+func parseChat(r *Room, self *User, text string) parsedChat {
+	result := parsedChat{message: html.UnescapeString(text)}
+
+	// If contains "/me" or "/em" at the front, it is an emote.
+	// The space following the command is not part of the message.
+	for _, prefix := range []string{"/me", "/em"} {
+		if !strings.HasPrefix(result.message, prefix) {
+			continue
+		}
+
+		rest := result.message[len(prefix):]
+		if rest == "" || rest[0] == ' ' {
+			result.emote = true
+			result.message = strings.TrimPrefix(rest, " ")
+			break
+		}
+	}
+
+	result.mentions, result.specials = parseMentions(r, self, result.message)
+
+	for _, match := range emotePattern.FindAllStringSubmatch(result.message, -1) {
+		result.emotes = append(result.emotes, match[1])
+	}
+
+	result.links = linkPattern.FindAllString(result.message, -1)
+	for i, link := range result.links {
+		// trailing punctuation is almost always part of the sentence
+		result.links[i] = strings.TrimRight(link, ".,!?;:)")
+	}
+
+	return result
+}
+
+// parseMentions finds every "@name" in the message, where the "@" starts
+// a word. Usernames may contain spaces, so for each "@" we take the longest
+// username in the room that the following text starts with. Special mentions
+// are expanded into the users they refer to, and every user is returned at
+// most once.
+func parseMentions(r *Room, self *User, message string) (mentions []*User, specials []mentionType) {
+	if !strings.Contains(message, "@") {
+		return nil, nil
+	}
+
+	// plug doesn't list us in the room, but we can be mentioned too
+	users := r.GetUsers()
+	if self != nil && !r.inRoom(self.ID) {
+		users = append(users, *self)
+	}
+
+	// Sort a copy by username length so that the first match
+	// is always the longest one ("@foo bar" over "@foo")
+	byLength := append([]User(nil), users...)
+	sort.SliceStable(byLength, func(i, j int) bool {
+		return len(byLength[i].Username) > len(byLength[j].Username)
+	})
+
+	seen := map[int]bool{}
+	add := func(u User) {
+		if seen[u.ID] {
+			return
+		}
+		seen[u.ID] = true
+		user := u
+		mentions = append(mentions, &user)
+	}
+
+	lower := strings.ToLower(message)
+	for i := strings.Index(lower, "@"); i != -1; {
+		rest := lower[i+1:]
+
+		// an "@" in the middle of a word (e.g. in an email address) isn't a mention
+		if isMentionStart(lower[:i]) {
+			matched := false
+			for word, special := range specialMentions {
+				if strings.HasPrefix(rest, word) && isMentionEnd(rest[len(word):]) {
+					specials = append(specials, special)
+					for _, u := range r.specialMentionUsers(special, users) {
+						add(u)
+					}
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				for _, u := range byLength {
+					name := strings.ToLower(u.Username)
+					if name != "" && strings.HasPrefix(rest, name) && isMentionEnd(rest[len(name):]) {
+						add(u)
+						break
+					}
+				}
+			}
+		}
+
+		next := strings.Index(rest, "@")
+		if next == -1 {
+			break
+		}
+		i += next + 1
+	}
+
+	return mentions, specials
+}
+
+// isMentionStart checks whether an "@" after this text can start
+// a mention, which it can at the start of the message or after a space
+func isMentionStart(before string) bool {
+	if before == "" {
+		return true
+	}
+
+	r, _ := utf8.DecodeLastRuneInString(before)
+	return unicode.IsSpace(r)
+}
+
+// isMentionEnd checks whether the text following a
+// possible mention means the mention has ended there
+func isMentionEnd(rest string) bool {
+	if rest == "" {
+		return true
+	}
+
+	switch rest[0] {
+	case ' ', ',', '.', '!', '?', ':', ';', ')', '\'', '"':
+		return true
+	}
+	return false
+}
+
+// specialMentionUsers returns which of the users (those in the room and
+// ourselves) are addressed by a special mention
+func (r *Room) specialMentionUsers(special mentionType, users []User) []User {
+	var addressed []User
+	switch special {
+	case EveryoneMention:
+		addressed = users
+	case DJsMention:
+		r.RLock()
+		djs := map[int]bool{}
+		if r.Booth.CurrentDJ > 0 {
+			djs[r.Booth.CurrentDJ] = true
+		}
+		for _, id := range r.Booth.WaitingDJs {
+			djs[id] = true
+		}
+		r.RUnlock()
+
+		for _, u := range users {
+			if djs[u.ID] {
+				addressed = append(addressed, u)
+			}
+		}
+	case StaffMention:
+		for _, u := range users {
+			if u.Role > 0 {
+				addressed = append(addressed, u)
+			}
+		}
+	}
+	return addressed
+}
+
+// The longest message plug will accept in chat, in characters
+const maxChatLength = 250
+
+// How long we wait for one of our messages to come back through chat
+// before giving up on deleting it. plug may have changed it on the way.
+const pendingDeleteTimeout = 30 * time.Second
+
+// pendingDelete is one of our own messages that should be
+// deleted a while after plug has given it a message ID
+type pendingDelete struct {
+	message string
+	after   time.Duration
+	sent    time.Time
+}
+
+// Reply sends text to chat addressed to whoever sent the chat message
+func (plug *PlugDJ) Reply(chat ChatPayload, text string) error {
+	if chat.User == nil {
+		return errors.New("go-plugapi: cannot reply to an unknown user")
+	}
+
+	return plug.Mention(chat.User, text)
+}
+
+// Mention sends text to chat prefixed with "@username". Messages too long
+// for a single chat message are split up, every part mentioning the user,
+// and every part is deleted after Config.ReplyDeleteAfter if that has been set.
+func (plug *PlugDJ) Mention(user *User, text string) error {
+	messages, err := mentionMessages(user, text)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		// the message can come back through chat before SendChat
+		// returns, so we must be waiting for it before it is sent
+		after := plug.config.ReplyDeleteAfter
+		pending := pendingDelete{message, after, time.Now()}
+		if after > 0 {
+			plug.addPendingDelete(pending)
+		}
+
+		if err := plug.SendChat(message); err != nil {
+			if after > 0 {
+				plug.removePendingDelete(pending)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mentionMessages splits text into chat messages that all start with "@username"
+func mentionMessages(user *User, text string) ([]string, error) {
+	if user == nil {
+		return nil, errors.New("go-plugapi: cannot mention a nil user")
+	}
+
+	name := escapeUsername(user.Username)
+	if name == "" {
+		return nil, errors.New("go-plugapi: cannot mention a user without a name")
+	}
+
+	prefix := "@" + name + " "
+	room := maxChatLength - utf8.RuneCountInString(prefix)
+	if room <= 0 {
+		return nil, errors.New("go-plugapi: username is too long to mention")
+	}
+
+	parts := splitChat(text, room)
+	if len(parts) == 0 {
+		parts = []string{""}
+	}
+
+	messages := make([]string, len(parts))
+	for i, part := range parts {
+		messages[i] = strings.TrimRight(prefix+part, " ")
+	}
+	return messages, nil
+}
+
+// addPendingDelete waits for one of our messages to come back
+// through chat so that it can be deleted
+func (plug *PlugDJ) addPendingDelete(pending pendingDelete) {
+	plug.pendingLock.Lock()
+	defer plug.pendingLock.Unlock()
+
+	plug.prunePendingDeletes(time.Now())
+	plug.pendingDeletes = append(plug.pendingDeletes, pending)
+}
+
+// removePendingDelete stops waiting for a message that was never sent
+func (plug *PlugDJ) removePendingDelete(pending pendingDelete) {
+	plug.pendingLock.Lock()
+	defer plug.pendingLock.Unlock()
+
+	for i, p := range plug.pendingDeletes {
+		if p == pending {
+			plug.pendingDeletes = append(plug.pendingDeletes[:i], plug.pendingDeletes[i+1:]...)
+			return
+		}
+	}
+}
+
+// prunePendingDeletes forgets messages that never came back,
+// plug.pendingLock must already be locked
+func (plug *PlugDJ) prunePendingDeletes(now time.Time) {
+	kept := plug.pendingDeletes[:0]
+	for _, pending := range plug.pendingDeletes {
+		if now.Sub(pending.sent) < pendingDeleteTimeout {
+			kept = append(kept, pending)
+		}
+	}
+	plug.pendingDeletes = kept
+}
+
+// deleteOwnMessageLater is called when one of our own messages
+// comes back through chat. If we are waiting to delete it,
+// the deletion is scheduled using its now known message ID.
+func (plug *PlugDJ) deleteOwnMessageLater(message, messageID string) {
+	plug.pendingLock.Lock()
+	defer plug.pendingLock.Unlock()
+
+	plug.prunePendingDeletes(time.Now())
+	for i, pending := range plug.pendingDeletes {
+		if pending.message != message {
+			continue
+		}
+
+		plug.pendingDeletes = append(plug.pendingDeletes[:i], plug.pendingDeletes[i+1:]...)
+		time.AfterFunc(pending.after, func() {
+			if err := plug.deleteMessage(messageID); err != nil {
+				plug.Log.WithField("cid", messageID).Warnln("could not delete own message", err)
+			}
+		})
+		return
+	}
+}
+
+// escapeUsername makes a username safe to put after an "@". Line
+// breaks, other control characters and invisible formatting characters
+// (e.g. right-to-left overrides) would break the mention, so they are
+// dropped entirely. Any other whitespace becomes a single plain space.
+func escapeUsername(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range name {
+		switch {
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// splitChat splits a message into parts no longer than limit
+// characters. Parts are split on the last space where possible.
+func splitChat(msg string, limit int) (parts []string) {
+	for utf8.RuneCountInString(msg) > limit {
+		// the byte offset of the limit'th character,
+		// a limit below one still gets one character
+		cut := 0
+		for n := 0; n < limit || cut == 0; n++ {
+			_, size := utf8.DecodeRuneInString(msg[cut:])
+			cut += size
+		}
+
+		// a space right at the cut is as good as it gets
+		if cut < len(msg) && msg[cut] != ' ' {
+			if space := strings.LastIndex(msg[:cut], " "); space > 0 {
+				cut = space
+			}
+		}
+
+		if part := strings.TrimRight(msg[:cut], " "); part != "" {
+			parts = append(parts, part)
+		}
+		msg = strings.TrimLeft(msg[cut:], " ")
+	}
+
+	if msg != "" {
+		parts = append(parts, msg)
+	}
+	return
+}
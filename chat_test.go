@@ -0,0 +1,231 @@
+package plugapi
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func mentionIDs(mentions []*User) []int {
+	var ids []int
+	for _, u := range mentions {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+func TestParseMentions(t *testing.T) {
+	room := &Room{}
+	room.SetUsers([]User{
+		{ID: 1, Username: "foo"},
+		{ID: 2, Username: "foo bar"},
+		{ID: 3, Username: "Staffer", Role: BouncerRole},
+		{ID: 4, Username: "dj"},
+	})
+	room.Booth.CurrentDJ = 4
+
+	tests := []struct {
+		name     string
+		self     *User
+		message  string
+		want     []int
+		specials []mentionType
+	}{
+		{"none", nil, "hello there", nil, nil},
+		{"simple", nil, "hi @foo!", []int{1}, nil},
+		{"longest name wins", nil, "hi @foo bar", []int{2}, nil},
+		{"case insensitive", nil, "@STAFFER hey", []int{3}, nil},
+		{"must end", nil, "@foox", nil, nil},
+		{"once each", nil, "@foo @foo", []int{1}, nil},
+		{"self", &User{ID: 9, Username: "bot"}, "@bot hi", []int{9}, nil},
+		{"everyone includes self", &User{ID: 9, Username: "bot"}, "@everyone", []int{1, 2, 3, 4, 9}, []mentionType{EveryoneMention}},
+		{"staff includes self by role", &User{ID: 9, Username: "bot", Role: ManagerRole}, "@staff", []int{3, 9}, []mentionType{StaffMention}},
+		{"staff excludes self without role", &User{ID: 9, Username: "bot"}, "@staff", []int{3}, []mentionType{StaffMention}},
+		{"djs", &User{ID: 9, Username: "bot"}, "@djs", []int{4}, []mentionType{DJsMention}},
+		{"email address", nil, "mail foo@foo.com", nil, nil},
+		{"email address to everyone", nil, "mail everyone@djs.com", nil, nil},
+		{"after a line break", nil, "hi\n@foo", []int{1}, nil},
+		{"after punctuation", nil, "(@foo)", nil, nil},
+	}
+
+	for _, test := range tests {
+		mentions, specials := parseMentions(room, test.self, test.message)
+		if got := mentionIDs(mentions); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: mentions = %v, want %v", test.name, got, test.want)
+		}
+		if !reflect.DeepEqual(specials, test.specials) {
+			t.Errorf("%s: specials = %v, want %v", test.name, specials, test.specials)
+		}
+	}
+}
+
+func djMentions(room *Room, self *User) []int {
+	mentions, _ := parseMentions(room, self, "@djs")
+	return mentionIDs(mentions)
+}
+
+func TestParseMentionsSelfDJ(t *testing.T) {
+	self := &User{ID: 9, Username: "bot"}
+	room := &Room{}
+	room.SetUsers([]User{{ID: 1, Username: "foo"}})
+
+	room.Booth.WaitingDJs = []int{9}
+	if got := djMentions(room, self); !reflect.DeepEqual(got, []int{9}) {
+		t.Errorf("waiting: mentions = %v, want [9]", got)
+	}
+
+	room.Booth.WaitingDJs = nil
+	room.Booth.CurrentDJ = 9
+	if got := djMentions(room, self); !reflect.DeepEqual(got, []int{9}) {
+		t.Errorf("playing: mentions = %v, want [9]", got)
+	}
+}
+
+func TestParseChat(t *testing.T) {
+	room := &Room{}
+	room.SetUsers([]User{{ID: 1, Username: "foo"}})
+
+	tests := []struct {
+		text    string
+		message string
+		emote   bool
+		emotes  []string
+		links   []string
+	}{
+		{"hello", "hello", false, nil, nil},
+		{"/me dances", "dances", true, nil, nil},
+		{"/em", "", true, nil, nil},
+		{"/meh", "/meh", false, nil, nil},
+		{"tom &amp; jerry", "tom & jerry", false, nil, nil},
+		{"nice :smile: :+1:", "nice :smile: :+1:", false, []string{"smile", "+1"}, nil},
+		{"see https://plug.dj/room.", "see https://plug.dj/room.", false, nil, []string{"https://plug.dj/room"}},
+		{"(www.example.com)", "(www.example.com)", false, nil, []string{"www.example.com"}},
+	}
+
+	for _, test := range tests {
+		got := parseChat(room, nil, test.text)
+		if got.message != test.message || got.emote != test.emote {
+			t.Errorf("parseChat(%q) = %q emote %t, want %q emote %t", test.text, got.message, got.emote, test.message, test.emote)
+		}
+		if !reflect.DeepEqual(got.emotes, test.emotes) {
+			t.Errorf("parseChat(%q) emotes = %v, want %v", test.text, got.emotes, test.emotes)
+		}
+		if !reflect.DeepEqual(got.links, test.links) {
+			t.Errorf("parseChat(%q) links = %v, want %v", test.text, got.links, test.links)
+		}
+	}
+}
+
+func TestSplitChat(t *testing.T) {
+	tests := []struct {
+		msg   string
+		limit int
+		want  []string
+	}{
+		{"", 10, nil},
+		{"short", 10, []string{"short"}},
+		{"exactly 10", 10, []string{"exactly 10"}},
+		{"hello there world", 11, []string{"hello there", "world"}},
+		{"hello   there", 7, []string{"hello", "there"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"héllo", 2, []string{"hé", "ll", "o"}},
+		{"日本", 1, []string{"日", "本"}},
+		{"日本語 です", 3, []string{"日本語", "です"}},
+		{"abc", 0, []string{"a", "b", "c"}},
+	}
+
+	for _, test := range tests {
+		if got := splitChat(test.msg, test.limit); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitChat(%q, %d) = %q, want %q", test.msg, test.limit, got, test.want)
+		}
+	}
+}
+
+func TestMentionFailureLeavesNothingPending(t *testing.T) {
+	plug := NewReplay(Config{Log: log.New(), ReplyDeleteAfter: time.Second})
+
+	// we have no socket, so sending fails
+	if err := plug.Mention(&User{ID: 1, Username: "foo"}, "hi"); err == nil {
+		t.Fatal("Mention without a socket succeeded")
+	}
+
+	if n := len(plug.pendingDeletes); n != 0 {
+		t.Errorf("%d pending deletes left after a failed send", n)
+	}
+}
+
+func TestPendingDeletesExpire(t *testing.T) {
+	plug := NewReplay(Config{Log: log.New()})
+	plug.pendingDeletes = []pendingDelete{
+		{"old", time.Second, time.Now().Add(-time.Hour)},
+		{"new", time.Second, time.Now()},
+	}
+
+	plug.deleteOwnMessageLater("old", "cid")
+
+	if len(plug.pendingDeletes) != 1 || plug.pendingDeletes[0].message != "new" {
+		t.Errorf("pending deletes = %+v, want only the new one", plug.pendingDeletes)
+	}
+}
+
+func TestEscapeUsername(t *testing.T) {
+	tests := map[string]string{
+		" foo\nbar\x00 ":  "foobar",
+		"foo\t \u00a0bar": "foo bar",
+		"foo\u202ebar":    "foobar", // right-to-left override
+		"foo\u200b":       "foo",    // zero width space
+		"\u2028":          "",
+		"日本 語":            "日本 語",
+	}
+
+	for name, want := range tests {
+		if got := escapeUsername(name); got != want {
+			t.Errorf("escapeUsername(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestMentionMessages(t *testing.T) {
+	foo := &User{ID: 1, Username: "foo"}
+
+	messages, err := mentionMessages(foo, "hi")
+	if err != nil || !reflect.DeepEqual(messages, []string{"@foo hi"}) {
+		t.Errorf("mentionMessages = %q, %v, want one message", messages, err)
+	}
+
+	messages, _ = mentionMessages(foo, "")
+	if !reflect.DeepEqual(messages, []string{"@foo"}) {
+		t.Errorf("mentionMessages without text = %q, want just the mention", messages)
+	}
+
+	// every part mentions them, and the limit is in characters
+	long := strings.Repeat("é", maxChatLength)
+	messages, _ = mentionMessages(foo, long)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	for i, message := range messages {
+		if !strings.HasPrefix(message, "@foo ") {
+			t.Errorf("message %d = %q, want it to mention foo", i, message)
+		}
+		if n := utf8.RuneCountInString(message); n > maxChatLength {
+			t.Errorf("message %d is %d characters long", i, n)
+		}
+	}
+	if got := strings.TrimPrefix(messages[0], "@foo ") + strings.TrimPrefix(messages[1], "@foo "); got != long {
+		t.Error("the parts don't add up to the text")
+	}
+
+	if _, err := mentionMessages(nil, "hi"); err == nil {
+		t.Error("mentioned a nil user")
+	}
+	if _, err := mentionMessages(&User{Username: "\n"}, "hi"); err == nil {
+		t.Error("mentioned a user without a name")
+	}
+	if _, err := mentionMessages(&User{Username: strings.Repeat("a", maxChatLength)}, "hi"); err == nil {
+		t.Error("mentioned a user with a name too long for chat")
+	}
+}
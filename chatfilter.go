@@ -0,0 +1,377 @@
+package plugapi
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// How long a strike takes to be forgotten, unless
+// ChatModeration.StrikeDecay says otherwise
+const defaultStrikeDecay = 10 * time.Minute
+
+// ChatFilter checks a chat message, returning why it breaks the
+// filter or "" if it doesn't. Reasons read like "it contains a link".
+type ChatFilter func(plug *PlugDJ, chat ChatPayload) string
+
+// ChatRule is a filter and who it applies to
+type ChatRule struct {
+	Name   string // used in logs and the ChatModerationEvent
+	Filter ChatFilter
+
+	// Users with at least this role (e.g. BouncerRole) aren't
+	// filtered. NoRole means that nobody is exempt. We only know
+	// the role of users in the room, anyone else is never exempt.
+	ExemptRole int
+}
+
+// ChatAction is what is done about a message that breaks a ChatRule.
+// Every action deletes the message, and the ones after it do more.
+type ChatAction int
+
+const (
+	DeleteChatAction ChatAction = iota // delete the message
+	WarnChatAction                     // and tell them why in chat
+	MuteChatAction                     // and mute them
+	BanChatAction                      // and ban them
+)
+
+// ChatModeration deletes chat messages that break its rules, and
+// does more about those that keep breaking them. Every message that
+// breaks a rule is a strike, and strikes are forgotten over time.
+// Every rule sees every message (so that e.g. a RepeatFilter counts
+// them all), but a message only gets one strike, for the first rule
+// it breaks.
+type ChatModeration struct {
+	Rules []ChatRule
+
+	// Escalation is what is done on someone's first, second, ... strike.
+	// Strikes past the end get the last action. Empty means delete only.
+	Escalation []ChatAction
+
+	// StrikeDecay is how long it takes for one strike
+	// to be forgotten. Zero means ten minutes.
+	StrikeDecay time.Duration
+
+	// How long to mute or ban for, zero means MuteShort and BanHour
+	MuteDuration MuteDuration
+	BanDuration  BanDuration
+
+	// plug's reason codes given for mutes and bans, zero means 1
+	MuteReason int
+	BanReason  int
+}
+
+// strike is how many strikes someone has, as of last
+type strike struct {
+	count int
+	last  time.Time
+}
+
+// WordFilter breaks messages containing any of the words, ignoring
+// case. Words only match whole words, so "heck" doesn't match "check",
+// but words starting or ending in punctuation (e.g. "c++") match
+// wherever that punctuation is.
+func WordFilter(words ...string) ChatFilter {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+
+		// \b would need a letter on both sides of it, and
+		// only knows about ASCII letters, so we spell it out
+		if first, _ := utf8.DecodeRuneInString(word); isWordRune(first) {
+			quoted[i] = `(?:^|[^\pL\pN_])` + quoted[i]
+		}
+		if last, _ := utf8.DecodeLastRuneInString(word); isWordRune(last) {
+			quoted[i] += `(?:$|[^\pL\pN_])`
+		}
+	}
+
+	pattern := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	return func(_ *PlugDJ, chat ChatPayload) string {
+		if len(words) > 0 && pattern.MatchString(chat.Message) {
+			return "it contains a banned word"
+		}
+		return ""
+	}
+}
+
+// isWordRune checks if the rune can be part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// PatternFilter breaks messages matching the pattern
+func PatternFilter(pattern *regexp.Regexp) ChatFilter {
+	return func(_ *PlugDJ, chat ChatPayload) string {
+		if pattern.MatchString(chat.Message) {
+			return "it isn't allowed"
+		}
+		return ""
+	}
+}
+
+// CapsFilter breaks messages with at least minLetters letters
+// where at least ratio (0 to 1) of the letters are capitals
+func CapsFilter(minLetters int, ratio float64) ChatFilter {
+	return func(_ *PlugDJ, chat ChatPayload) string {
+		letters, upper := 0, 0
+		for _, r := range chat.Message {
+			if unicode.IsLetter(r) {
+				letters++
+				if unicode.IsUpper(r) {
+					upper++
+				}
+			}
+		}
+
+		if letters >= minLetters && letters > 0 && float64(upper)/float64(letters) >= ratio {
+			return "it is mostly capitals"
+		}
+		return ""
+	}
+}
+
+// RepeatFilter breaks the message a user sends for the times'th
+// time within the duration, ignoring case and surrounding spaces
+func RepeatFilter(times int, within time.Duration) ChatFilter {
+	type sent struct {
+		message string
+		at      time.Time
+	}
+
+	var lock sync.Mutex
+	history := make(map[int][]sent)
+
+	return func(_ *PlugDJ, chat ChatPayload) string {
+		if chat.User == nil {
+			return ""
+		}
+
+		now := time.Now()
+		message := strings.ToLower(strings.TrimSpace(chat.Message))
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		// forget what was sent too long ago, by everyone
+		for uid, messages := range history {
+			kept := messages[:0]
+			for _, m := range messages {
+				if now.Sub(m.at) <= within {
+					kept = append(kept, m)
+				}
+			}
+
+			if len(kept) == 0 {
+				delete(history, uid)
+			} else {
+				history[uid] = kept
+			}
+		}
+
+		uid := chat.User.ID
+		history[uid] = append(history[uid], sent{message, now})
+
+		count := 0
+		for _, m := range history[uid] {
+			if m.message == message {
+				count++
+			}
+		}
+
+		if count >= times {
+			return "it was repeated"
+		}
+		return ""
+	}
+}
+
+// LinkFilter breaks messages with links, except to the allowed
+// hosts (e.g. "youtube.com", which allows "www.youtube.com" too)
+func LinkFilter(allowed ...string) ChatFilter {
+	return func(_ *PlugDJ, chat ChatPayload) string {
+		for _, link := range chat.Links {
+			if !strings.Contains(link, "://") {
+				link = "http://" + link
+			}
+
+			u, err := url.Parse(link)
+			if err != nil || !allowedHost(strings.ToLower(u.Hostname()), allowed) {
+				return "it contains a link"
+			}
+		}
+		return ""
+	}
+}
+
+func allowedHost(host string, allowed []string) bool {
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if host == a || strings.HasSuffix(host, "."+a) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterChat checks the message against Config.ChatModeration,
+// returning true if it broke a rule and is being dealt with
+func (plug *PlugDJ) filterChat(chat ChatPayload, userID int) bool {
+	moderation := plug.config.ChatModeration
+	if moderation == nil {
+		return false
+	}
+
+	// every rule sees the message, as filters may keep track of them
+	var broken *ChatRule
+	var reason string
+	for i, rule := range moderation.Rules {
+		if rule.Filter == nil {
+			continue
+		}
+
+		if rule.ExemptRole != NoRole && chat.User != nil && chat.User.Role >= rule.ExemptRole {
+			continue
+		}
+
+		if r := rule.Filter(plug, chat); r != "" && broken == nil {
+			broken, reason = &moderation.Rules[i], r
+		}
+	}
+
+	if broken == nil {
+		return false
+	}
+
+	rule := *broken
+	strikes := plug.addStrike(userID)
+	action := DeleteChatAction
+	if n := len(moderation.Escalation); n > 0 {
+		if strikes > n {
+			action = moderation.Escalation[n-1]
+		} else {
+			action = moderation.Escalation[strikes-1]
+		}
+	}
+
+	plug.Log.WithFields(log.Fields{
+		"rule":    rule.Name,
+		"reason":  reason,
+		"uid":     userID,
+		"strikes": strikes,
+	}).Infoln("chat message broke rule")
+
+	plug.background(func() {
+		err := plug.applyChatAction(action, chat, userID, reason)
+		if err != nil {
+			plug.Log.WithField("error", err).Warnln("could not moderate chat message")
+		}
+
+		plug.emitEvent(ChatModerationEvent, ChatModerationPayload{
+			Chat:    chat,
+			Rule:    rule.Name,
+			Reason:  reason,
+			Strikes: strikes,
+			Action:  action,
+			Err:     err,
+		})
+	})
+	return true
+}
+
+// strikeDecay is how long a strike takes to be forgotten
+func (plug *PlugDJ) strikeDecay() time.Duration {
+	if moderation := plug.config.ChatModeration; moderation != nil && moderation.StrikeDecay > 0 {
+		return moderation.StrikeDecay
+	}
+	return defaultStrikeDecay
+}
+
+// current returns how many strikes are left, forgetting
+// one for every decay since the last strike
+func (s strike) current(now time.Time, decay time.Duration) int {
+	count := s.count - int(now.Sub(s.last)/decay)
+	if count < 0 {
+		return 0
+	}
+	return count
+}
+
+// addStrike gives the user a strike, returning how many they have now
+func (plug *PlugDJ) addStrike(userID int) int {
+	now := time.Now()
+	decay := plug.strikeDecay()
+
+	plug.strikeLock.Lock()
+	defer plug.strikeLock.Unlock()
+
+	// forget everyone whose strikes are all gone
+	for uid, s := range plug.strikes {
+		if s.current(now, decay) == 0 {
+			delete(plug.strikes, uid)
+		}
+	}
+
+	count := 1
+	if s, ok := plug.strikes[userID]; ok {
+		count += s.current(now, decay)
+	}
+
+	plug.strikes[userID] = &strike{count, now}
+	return count
+}
+
+// Strikes returns how many strikes the user has
+// for breaking Config.ChatModeration rules
+func (plug *PlugDJ) Strikes(userID int) int {
+	plug.strikeLock.Lock()
+	defer plug.strikeLock.Unlock()
+
+	if s, ok := plug.strikes[userID]; ok {
+		return s.current(time.Now(), plug.strikeDecay())
+	}
+	return 0
+}
+
+// applyChatAction deletes the message and does whatever else the
+// action says, returning the first error
+func (plug *PlugDJ) applyChatAction(action ChatAction, chat ChatPayload, userID int, reason string) error {
+	moderation := plug.config.ChatModeration
+
+	if err := plug.moderateDelete(chat.MessageID, userID, reason); err != nil {
+		return err
+	}
+
+	switch action {
+	case WarnChatAction:
+		if chat.User != nil {
+			return plug.Mention(chat.User, "your message was deleted, "+reason)
+		}
+	case MuteChatAction:
+		duration, code := moderation.MuteDuration, moderation.MuteReason
+		if duration == "" {
+			duration = MuteShort
+		}
+		if code == 0 {
+			code = 1
+		}
+		return plug.ModerateMute(userID, duration, code)
+	case BanChatAction:
+		duration, code := moderation.BanDuration, moderation.BanReason
+		if duration == "" {
+			duration = BanHour
+		}
+		if code == 0 {
+			code = 1
+		}
+		return plug.ModerateBan(userID, duration, code)
+	}
+
+	return nil
+}
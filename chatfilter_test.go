@@ -0,0 +1,201 @@
+package plugapi
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChatFilters(t *testing.T) {
+	alice := &User{ID: 2, Username: "alice"}
+	tests := []struct {
+		name   string
+		filter ChatFilter
+		chat   ChatPayload
+		want   string
+	}{
+		{"word", WordFilter("heck"), ChatPayload{Message: "what the HECK"}, "it contains a banned word"},
+		{"word inside another", WordFilter("heck"), ChatPayload{Message: "check this"}, ""},
+		{"no words", WordFilter(), ChatPayload{Message: "anything"}, ""},
+		{"word with punctuation", WordFilter("c++"), ChatPayload{Message: "I write c++."}, "it contains a banned word"},
+		{"word ending in punctuation", WordFilter("heck!"), ChatPayload{Message: "oh heck!!"}, "it contains a banned word"},
+		{"word with accents", WordFilter("café"), ChatPayload{Message: "CAFÉ time"}, "it contains a banned word"},
+		{"word with accents inside another", WordFilter("café"), ChatPayload{Message: "cafés"}, ""},
+		{"pattern", PatternFilter(regexp.MustCompile(`^!`)), ChatPayload{Message: "!cmd"}, "it isn't allowed"},
+		{"pattern misses", PatternFilter(regexp.MustCompile(`^!`)), ChatPayload{Message: "hi!"}, ""},
+		{"caps", CapsFilter(5, 0.8), ChatPayload{Message: "HELLO THERE"}, "it is mostly capitals"},
+		{"caps too short", CapsFilter(5, 0.8), ChatPayload{Message: "OK"}, ""},
+		{"not caps", CapsFilter(5, 0.8), ChatPayload{Message: "Hello There"}, ""},
+		{"link", LinkFilter("youtube.com"), ChatPayload{Links: []string{"https://evil.example/x"}}, "it contains a link"},
+		{"allowed link", LinkFilter("youtube.com"), ChatPayload{Links: []string{"https://www.YouTube.com/watch?v=x", "youtube.com/y"}}, ""},
+		{"lookalike link", LinkFilter("youtube.com"), ChatPayload{Links: []string{"notyoutube.com"}}, "it contains a link"},
+	}
+
+	for _, test := range tests {
+		test.chat.User = alice
+		if got := test.filter(nil, test.chat); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRepeatFilter(t *testing.T) {
+	alice, bob := &User{ID: 2}, &User{ID: 3}
+	filter := RepeatFilter(3, 50*time.Millisecond)
+
+	for i, chat := range []ChatPayload{
+		{User: alice, Message: "hi"},
+		{User: bob, Message: "hi"},
+		{User: alice, Message: " HI "},
+		{User: alice, Message: "something else"},
+	} {
+		if got := filter(nil, chat); got != "" {
+			t.Errorf("message %d: got %q, want no repeat yet", i, got)
+		}
+	}
+
+	if got := filter(nil, ChatPayload{User: alice, Message: "hi"}); got != "it was repeated" {
+		t.Errorf("third hi: got %q, want a repeat", got)
+	}
+
+	// they are forgotten after a while
+	time.Sleep(60 * time.Millisecond)
+	if got := filter(nil, ChatPayload{User: alice, Message: "hi"}); got != "" {
+		t.Errorf("hi after a while: got %q, want no repeat", got)
+	}
+}
+
+func TestStrikeDecay(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		strike strike
+		want   int
+	}{
+		{strike{3, now}, 3},
+		{strike{3, now.Add(-9 * time.Minute)}, 3},
+		{strike{3, now.Add(-10 * time.Minute)}, 2},
+		{strike{3, now.Add(-25 * time.Minute)}, 1},
+		{strike{3, now.Add(-time.Hour)}, 0},
+	}
+
+	for _, test := range tests {
+		if got := test.strike.current(now, 10*time.Minute); got != test.want {
+			t.Errorf("%d strikes from %s ago = %d, want %d", test.strike.count, now.Sub(test.strike.last), got, test.want)
+		}
+	}
+}
+
+func TestAddStrike(t *testing.T) {
+	plug := &PlugDJ{
+		config:  &Config{ChatModeration: &ChatModeration{StrikeDecay: time.Hour}},
+		strikes: make(map[int]*strike),
+	}
+
+	if n := plug.addStrike(2); n != 1 {
+		t.Errorf("first strike = %d, want 1", n)
+	}
+	if n := plug.addStrike(2); n != 2 {
+		t.Errorf("second strike = %d, want 2", n)
+	}
+	if n := plug.Strikes(3); n != 0 {
+		t.Errorf("Strikes(3) = %d, want 0", n)
+	}
+
+	// strikes that were forgotten don't count, and the user is forgotten too
+	plug.strikes[2].last = time.Now().Add(-3 * time.Hour)
+	if n := plug.Strikes(2); n != 0 {
+		t.Errorf("Strikes(2) = %d after they decayed, want 0", n)
+	}
+	plug.addStrike(3)
+	if _, ok := plug.strikes[2]; ok {
+		t.Error("user with no strikes left is still remembered")
+	}
+}
+
+func TestFilterChat(t *testing.T) {
+	var lock sync.Mutex
+	var requests []string
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path[len("/_"):])
+		lock.Unlock()
+		writeEnvelope(w, "ok", []struct{}{})
+	})
+	plug.eventFuncs = make(map[Event]ProcessPayloadFunc)
+	plug.strikes = make(map[int]*strike)
+	plug.Room = &Room{}
+	plug.config.ChatModeration = &ChatModeration{
+		Rules: []ChatRule{
+			{Name: "words", Filter: WordFilter("heck"), ExemptRole: BouncerRole},
+		},
+		Escalation: []ChatAction{DeleteChatAction, MuteChatAction},
+	}
+	moderated := listenFor(plug, ChatModerationEvent)
+
+	alice := &User{ID: 2, Username: "alice"}
+	if plug.filterChat(ChatPayload{MessageID: "2-1", User: alice, Message: "hello"}, 2) {
+		t.Error("a fine message was filtered")
+	}
+
+	staff := &User{ID: 3, Role: ManagerRole}
+	if plug.filterChat(ChatPayload{MessageID: "3-1", User: staff, Message: "heck"}, 3) {
+		t.Error("staff were filtered")
+	}
+
+	for i, want := range []ChatAction{DeleteChatAction, MuteChatAction, MuteChatAction} {
+		if !plug.filterChat(ChatPayload{MessageID: "2-2", User: alice, Message: "heck"}, 2) {
+			t.Fatalf("strike %d wasn't filtered", i+1)
+		}
+
+		payload := nextPayload(t, moderated).(ChatModerationPayload)
+		if payload.Rule != "words" || payload.Strikes != i+1 || payload.Action != want || payload.Err != nil {
+			t.Errorf("strike %d: event = %+v, want %d strikes and action %d", i+1, payload, i+1, want)
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	want := []string{
+		"DELETE " + ChatDeleteEndpoint + "2-2",
+		"DELETE " + ChatDeleteEndpoint + "2-2", "POST " + ModerateMuteEndpoint,
+		"DELETE " + ChatDeleteEndpoint + "2-2", "POST " + ModerateMuteEndpoint,
+	}
+	if strings.Join(requests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+}
+
+func TestFilterChatRunsEveryRule(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		writeEnvelope(w, "ok", []struct{}{})
+	})
+	plug.eventFuncs = make(map[Event]ProcessPayloadFunc)
+	plug.strikes = make(map[int]*strike)
+	plug.Room = &Room{}
+	plug.config.ChatModeration = &ChatModeration{
+		Rules: []ChatRule{
+			{Name: "caps", Filter: CapsFilter(5, 0.8)},
+			{Name: "repeat", Filter: RepeatFilter(2, time.Minute)},
+		},
+	}
+	moderated := listenFor(plug, ChatModerationEvent)
+
+	// the first message only breaks the first rule, but counts for the second
+	alice := &User{ID: 2, Username: "alice"}
+	for i, want := range []string{"caps", "repeat"} {
+		message := "HELLO THERE"
+		if i > 0 {
+			message = "hello there"
+		}
+
+		if !plug.filterChat(ChatPayload{MessageID: "2-1", User: alice, Message: message}, 2) {
+			t.Fatalf("message %d wasn't filtered", i+1)
+		}
+		if payload := nextPayload(t, moderated).(ChatModerationPayload); payload.Rule != want || payload.Strikes != i+1 {
+			t.Errorf("message %d: event = %+v, want rule %q and %d strikes", i+1, payload, want, i+1)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package plugapi
+
+import (
+	"encoding/json"
+	"errors"
+	log "github.com/Sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// Formats plug sends times in. They are always in UTC.
+var timestampFormats = []string{
+	"2006-01-02 15:04:05.000000",
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano, // what we write ourselves, e.g. in recordings
+}
+
+// parseTimestamp parses a time sent by plug. Empty strings are zero times.
+func parseTimestamp(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	for _, format := range timestampFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.New("plugapi: unknown time format " + s)
+}
+
+// parseTimestampField is parseTimestamp for times in objects sent by plug.
+// A time we can't read is left zero rather than losing the whole object
+// over it. There's no PlugDJ to hand, so it's logged to logrus' own logger.
+func parseTimestampField(field, s string) time.Time {
+	t, err := parseTimestamp(s)
+	if err != nil {
+		log.WithField(field, s).Warnln("plugapi: ignoring time in unknown format")
+	}
+	return t
+}
+
+// Timestamp is a time sent by plug, for fields of types that
+// can't parse it themselves (such as User, which is embedded in others)
+type Timestamp struct {
+	time.Time
+}
+
+// UnmarshalJSON parses a time in any of the formats plug uses
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	t.Time = time.Time{}
+	if s != nil {
+		t.Time = parseTimestampField("timestamp", *s)
+	}
+	return nil
+}
+
+// ServerClock keeps track of how far plug's clock is from ours.
+// It starts from the precise time on plug's homepage (_st) and
+// is corrected using the time on every socket message.
+type ServerClock struct {
+	sync.RWMutex
+	skew time.Duration // plug's time minus ours
+}
+
+// Socket messages only have their time to the second, and arrive
+// late. Corrections smaller than this are down to that, not drift.
+const clockResolution = time.Second
+
+// Skew returns how far ahead plug's clock is from ours
+func (c *ServerClock) Skew() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.skew
+}
+
+// Now returns the time it is for plug
+func (c *ServerClock) Now() time.Time {
+	return time.Now().Add(c.Skew())
+}
+
+// set sets the skew from a precise time from plug
+func (c *ServerClock) set(serverTime time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.skew = serverTime.Sub(time.Now())
+}
+
+// observe corrects the skew from a time from a socket message.
+// That time is truncated to the second, so on average it's half a
+// second behind. We only move an eighth of the way towards each
+// sample so that a single slow message can't throw us off.
+func (c *ServerClock) observe(serverTime time.Time) {
+	sample := serverTime.Add(clockResolution / 2).Sub(time.Now())
+
+	c.Lock()
+	defer c.Unlock()
+
+	diff := sample - c.skew
+	if diff > -clockResolution && diff < clockResolution {
+		return
+	}
+
+	c.skew += diff / 8
+}
+
+// Clock returns the clock used to keep our time in line with plug's
+func (plug *PlugDJ) Clock() *ServerClock {
+	return plug.clock
+}
+
+// ServerNow returns the time it is for plug
+func (plug *PlugDJ) ServerNow() time.Time {
+	return plug.clock.Now()
+}
+
+// UnmarshalJSON parses the startTime sent by plug
+func (p *Playback) UnmarshalJSON(data []byte) error {
+	type playback Playback
+	raw := struct {
+		*playback
+		StartTime string `json:"startTime"`
+	}{playback: (*playback)(p)}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	p.StartTime = parseTimestampField("startTime", raw.StartTime)
+	return nil
+}
+
+// UnmarshalJSON parses the timestamp sent by plug
+func (h *HistoryItem) UnmarshalJSON(data []byte) error {
+	type historyItem HistoryItem
+	raw := struct {
+		*historyItem
+		Timestamp string `json:"timestamp"`
+	}{historyItem: (*historyItem)(h)}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	h.Timestamp = parseTimestampField("timestamp", raw.Timestamp)
+	return nil
+}
+
+// UnmarshalJSON parses the timestamp sent by plug
+func (f *FriendRequest) UnmarshalJSON(data []byte) error {
+	type friendRequest FriendRequest
+	raw := struct {
+		*friendRequest
+		Timestamp string `json:"timestamp"`
+	}{friendRequest: (*friendRequest)(f)}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	f.Timestamp = parseTimestampField("timestamp", raw.Timestamp)
+	return nil
+}
@@ -0,0 +1,117 @@
+package plugapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"", time.Time{}, true},
+		{"2017-03-04 05:06:07.123456", time.Date(2017, 3, 4, 5, 6, 7, 123456000, time.UTC), true},
+		{"2017-03-04 05:06:07", time.Date(2017, 3, 4, 5, 6, 7, 0, time.UTC), true},
+		{"2017-03-04T05:06:07.5Z", time.Date(2017, 3, 4, 5, 6, 7, 500000000, time.UTC), true},
+		{"yesterday", time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		got, err := parseTimestamp(test.in)
+		if (err == nil) != test.ok || !got.Equal(test.want) {
+			t.Errorf("parseTimestamp(%q) = %s, %v, want %s (ok %t)", test.in, got, err, test.want, test.ok)
+		}
+	}
+}
+
+func TestServerClock(t *testing.T) {
+	var clock ServerClock
+
+	clock.set(time.Now().Add(time.Minute))
+	if skew := clock.Skew(); skew < 59*time.Second || skew > time.Minute {
+		t.Fatalf("skew after set = %s, want about a minute", skew)
+	}
+
+	// socket times within a second are just their resolution
+	before := clock.Skew()
+	clock.observe(time.Now().Add(time.Minute).Truncate(time.Second))
+	if clock.Skew() != before {
+		t.Errorf("skew moved from %s to %s on a sample within resolution", before, clock.Skew())
+	}
+
+	// far off samples only move it part of the way
+	clock.observe(time.Now())
+	if skew := clock.Skew(); skew >= before || skew <= 0 {
+		t.Errorf("skew = %s after a sample of zero, want between 0 and %s", skew, before)
+	}
+}
+
+func TestTimestampUnmarshal(t *testing.T) {
+	var playback Playback
+	if err := json.Unmarshal([]byte(`{"historyID":"h","startTime":"2017-03-04 05:06:07.000000"}`), &playback); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2017, 3, 4, 5, 6, 7, 0, time.UTC); !playback.StartTime.Equal(want) || playback.HistoryID != "h" {
+		t.Errorf("playback = %+v, want start %s", playback, want)
+	}
+
+	var item HistoryItem
+	if err := json.Unmarshal([]byte(`{"id":"i","timestamp":"2017-03-04 05:06:07"}`), &item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Timestamp.IsZero() || item.ID != "i" {
+		t.Errorf("history item = %+v", item)
+	}
+
+	// and they survive a round trip, as in recordings
+	data, err := json.Marshal(playback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again Playback
+	if err := json.Unmarshal(data, &again); err != nil || !again.StartTime.Equal(playback.StartTime) {
+		t.Errorf("round trip = %+v, %v", again, err)
+	}
+
+	// a time we can't read doesn't lose us the rest
+	var bad Playback
+	if err := json.Unmarshal([]byte(`{"historyID":"h","startTime":"whenever"}`), &bad); err != nil {
+		t.Errorf("unmarshalling a bad start time failed: %v", err)
+	}
+	if !bad.StartTime.IsZero() || bad.HistoryID != "h" {
+		t.Errorf("playback with a bad start time = %+v, want a zero start", bad)
+	}
+}
+
+func TestUserJoined(t *testing.T) {
+	var user User
+	if err := json.Unmarshal([]byte(`{"id":2,"joined":"2014-05-06 07:08:09.000000"}`), &user); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2014, 5, 6, 7, 8, 9, 0, time.UTC); !user.Joined.Equal(want) {
+		t.Errorf("joined = %s, want %s", user.Joined, want)
+	}
+
+	// types embedding a user still get their own fields
+	var profile Profile
+	data, err := json.Marshal(Profile{User: user, XP: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &profile); err != nil {
+		t.Fatal(err)
+	}
+	if profile.XP != 10 || !profile.Joined.Equal(user.Joined.Time) {
+		t.Errorf("profile round trip = %+v", profile)
+	}
+
+	for _, data := range []string{`{"joined":null}`, `{"joined":""}`, `{"joined":"whenever"}`} {
+		user := User{Joined: Timestamp{time.Now()}}
+		if err := json.Unmarshal([]byte(data), &user); err != nil || !user.Joined.IsZero() {
+			t.Errorf("%s: joined = %s, %v, want zero", data, user.Joined, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package plugapi
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -13,6 +14,82 @@ var (
 	ErrUnknownData            = errors.New("plugapi: cannot structify request")
 )
 
+// Errors for the common statuses plug replies with. Use errors.Is
+// to check an APIError against these, errors.As to get the APIError.
+var (
+	ErrNotAuthorized = errors.New("plugapi: not authorized")    // "notAuthorized"
+	ErrNotFound      = errors.New("plugapi: not found")         // "notFound"
+	ErrRateLimited   = errors.New("plugapi: rate limited")      // "rateLimit"
+	ErrRequestError  = errors.New("plugapi: request error")     // "requestError"
+	ErrServerError   = errors.New("plugapi: plug server error") // any 5xx
+)
+
+// Map of plug statuses to the error they represent
+var statusErrors = map[string]error{
+	"notAuthorized": ErrNotAuthorized,
+	"notFound":      ErrNotFound,
+	"rateLimit":     ErrRateLimited,
+	"requestError":  ErrRequestError,
+}
+
+// APIError is returned when plug replies with an HTTP status
+// other than 200, or with a status other than "ok"
+type APIError struct {
+	StatusCode int             // the HTTP status code
+	Endpoint   string          // e.g. "/rooms/join"
+	Status     string          // e.g. "notAuthorized", empty if plug didn't say
+	Data       json.RawMessage // usually a list of error messages
+
+	// The response, its body has already been read into
+	// Status and Data. Useful for looking at headers.
+	Response *http.Response
+}
+
+func (e *APIError) Error() string {
+	status := e.Status
+	if status == "" {
+		status = http.StatusText(e.StatusCode)
+	}
+
+	if len(e.Data) == 0 {
+		return fmt.Sprintf("plugapi: %s (%d) from %s", status, e.StatusCode, e.Endpoint)
+	}
+	return fmt.Sprintf("plugapi: %s (%d) from %s: %s", status, e.StatusCode, e.Endpoint, e.Data)
+}
+
+// Is makes errors.Is(err, ErrNotAuthorized) and friends work. plug's own
+// status is used if it gave one, otherwise the HTTP status code is.
+func (e *APIError) Is(target error) bool {
+	if sentinel, ok := statusErrors[e.Status]; ok {
+		return sentinel == target
+	}
+
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return target == ErrNotAuthorized
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	case http.StatusBadRequest:
+		return target == ErrRequestError
+	}
+
+	return e.StatusCode >= 500 && target == ErrServerError
+}
+
+// asAPIError finds the APIError in err, if there is one
+func asAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	ok := errors.As(err, &apiErr)
+	return apiErr, ok
+}
+
+// ErrDataRequestError was returned when plug replied with a status
+// other than "ok".
+//
+// Deprecated: plug's replies are now returned as an *APIError,
+// use ErrIsDataRequestError or errors.As to check for them.
 type ErrDataRequestError struct {
 	Data     interface{}
 	Endpoint string
@@ -22,6 +99,11 @@ func (e ErrDataRequestError) Error() string {
 	return fmt.Sprintf("plugapi: invalid data from %s: %v", e.Endpoint, e.Data)
 }
 
+// ErrUnknownResponse was returned when plug replied with an HTTP
+// status other than 200.
+//
+// Deprecated: plug's replies are now returned as an *APIError,
+// use ErrIsUnknownResponse or errors.As to check for them.
 type ErrUnknownResponse struct {
 	Response *http.Response
 	Endpoint string
@@ -31,20 +113,26 @@ func (e ErrUnknownResponse) Error() string {
 	return fmt.Sprintf("plugapi: bad reply. error %d from %s", e.Response.StatusCode, e.Endpoint)
 }
 
+// ErrIsUnknownResponse checks if plug replied with an HTTP status other than 200
+//
+// Deprecated: use errors.As with an *APIError and look at its StatusCode.
 func ErrIsUnknownResponse(err error) bool {
-	switch err.(type) {
-	case *ErrUnknownResponse:
+	if _, ok := err.(*ErrUnknownResponse); ok {
 		return true
-	default:
-		return false
 	}
+
+	apiErr, ok := asAPIError(err)
+	return ok && apiErr.StatusCode != http.StatusOK
 }
 
+// ErrIsDataRequestError checks if plug replied with a status other than "ok"
+//
+// Deprecated: use errors.As with an *APIError and look at its Status.
 func ErrIsDataRequestError(err error) bool {
-	switch err.(type) {
-	case *ErrDataRequestError:
+	if _, ok := err.(*ErrDataRequestError); ok {
 		return true
-	default:
-		return false
 	}
+
+	apiErr, ok := asAPIError(err)
+	return ok && apiErr.StatusCode == http.StatusOK && apiErr.Status != ""
 }
@@ -0,0 +1,49 @@
+package plugapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		err  *APIError
+		want error
+	}{
+		{&APIError{StatusCode: http.StatusOK, Status: "notAuthorized"}, ErrNotAuthorized},
+		{&APIError{StatusCode: http.StatusBadRequest, Status: "notFound"}, ErrNotFound},
+		{&APIError{StatusCode: http.StatusForbidden}, ErrNotAuthorized},
+		{&APIError{StatusCode: http.StatusNotFound}, ErrNotFound},
+		{&APIError{StatusCode: http.StatusTooManyRequests}, ErrRateLimited},
+		{&APIError{StatusCode: http.StatusBadRequest}, ErrRequestError},
+		{&APIError{StatusCode: http.StatusBadGateway}, ErrServerError},
+	}
+
+	for _, test := range tests {
+		wrapped := fmt.Errorf("wrapped: %w", test.err)
+		if !errors.Is(wrapped, test.want) {
+			t.Errorf("errors.Is(%v, %v) = false, want true", test.err, test.want)
+		}
+	}
+
+	if errors.Is(&APIError{StatusCode: http.StatusNotFound}, ErrServerError) {
+		t.Error("a 404 is a server error")
+	}
+}
+
+func TestDeprecatedErrorChecks(t *testing.T) {
+	status := &APIError{StatusCode: http.StatusBadRequest}
+	envelope := &APIError{StatusCode: http.StatusOK, Status: "requestError"}
+
+	if !ErrIsUnknownResponse(status) || ErrIsUnknownResponse(envelope) {
+		t.Error("ErrIsUnknownResponse should only match bad HTTP statuses")
+	}
+	if !ErrIsDataRequestError(envelope) || ErrIsDataRequestError(status) {
+		t.Error("ErrIsDataRequestError should only match bad plug statuses")
+	}
+	if !ErrIsUnknownResponse(&ErrUnknownResponse{}) || !ErrIsDataRequestError(&ErrDataRequestError{}) {
+		t.Error("the old error types are no longer recognised")
+	}
+}
@@ -13,9 +13,35 @@ type ProcessPayloadFunc func(plug *PlugDJ, payload interface{})
 
 func (plug *PlugDJ) emitEvent(event Event, payload interface{}) {
 	fn := plug.eventFuncs[event]
+	hook := plug.config.eventHook
+
+	// a replay has to be the same every time it is played
+	if plug.replaying {
+		if fn != nil {
+			fn(plug, payload)
+		}
+		if hook != nil {
+			hook(plug, event, payload)
+		}
+		return
+	}
+
 	if fn != nil {
 		go fn(plug, payload)
 	}
+	if hook != nil {
+		go hook(plug, event, payload)
+	}
+}
+
+// background runs work that handlers shouldn't wait for (e.g. moderation)
+// in its own goroutine. While replaying it is run straight away instead.
+func (plug *PlugDJ) background(fn func()) {
+	if plug.replaying {
+		fn()
+		return
+	}
+	go fn()
 }
 
 // List of Event types
@@ -72,4 +98,14 @@ const (
 	UserLeaveEvent                           // = "userLeave"
 	UserUpdateEvent                          // = "userUpdate"
 	VoteEvent                                // = "vote"
+
+	// Events that aren't plug's own go below, in the order they were
+	// added, so that the values of the events above never change
+	LevelUpEvent         // not a plug event, sent when our level changes
+	HeartbeatMissedEvent // not a plug event, sent when the socket goes quiet
+	StuckTrackEvent      // not a plug event, sent when a play outlasts its media
+	TrackEndingEvent     // not a plug event, sent Config.TrackEndingNotice before a play ends
+	UserIdleEvent        // not a plug event, sent when a waiting DJ is idle for Config.IdleTimeout
+	MediaPolicyEvent     // not a plug event, sent when a play breaks one of Config.MediaPolicies
+	ChatModerationEvent  // not a plug event, sent when a message breaks Config.ChatModeration
 )
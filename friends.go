@@ -0,0 +1,52 @@
+package plugapi
+
+import "time"
+
+// Friend is someone on the friends list of the logged in user
+type Friend struct {
+	User
+
+	Status int `json:"status"` // 0 when offline
+	Room   *struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"room"` // The room they are in, nil if they aren't in one
+}
+
+// FriendRequest is a pending request from someone
+// wanting to be friends with the logged in user
+type FriendRequest struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GetFriends returns the friends of the logged in user
+func (plug *PlugDJ) GetFriends() ([]Friend, error) {
+	var friends []Friend
+	if err := plug.GetData(FriendsEndpoint, &friends, nil); err != nil {
+		return nil, err
+	}
+	return friends, nil
+}
+
+// GetFriendRequests returns the friend requests
+// the logged in user hasn't responded to yet
+func (plug *PlugDJ) GetFriendRequests() ([]FriendRequest, error) {
+	var requests []FriendRequest
+	if err := plug.GetData(FriendInvitesEndpoint, &requests, nil); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// AcceptFriendRequest accepts the friend request from the user.
+// This also sends a friend request if there wasn't one.
+func (plug *PlugDJ) AcceptFriendRequest(userID int) error {
+	return plug.PostData(FriendsEndpoint, map[string]int{"id": userID}, nil, nil)
+}
+
+// IgnoreFriendRequest ignores the friend request from the user
+func (plug *PlugDJ) IgnoreFriendRequest(userID int) error {
+	return plug.PutData(FriendIgnoreEndpoint, map[string]int{"id": userID}, nil, nil)
+}
@@ -0,0 +1,78 @@
+package plugapi
+
+import (
+	"encoding/json"
+	log "github.com/Sirupsen/logrus"
+	"net/http"
+	"testing"
+)
+
+func TestGetFriends(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok","data":[
+			{"id":2,"username":"alice","status":1,"room":{"name":"Test","slug":"test"}},
+			{"id":3,"username":"bob","status":0,"room":null}
+		]}`))
+	})
+
+	friends, err := plug.GetFriends()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(friends) != 2 {
+		t.Fatalf("got %d friends, want 2", len(friends))
+	}
+	if friends[0].Room == nil || friends[0].Room.Slug != "test" {
+		t.Errorf("alice is in %+v, want the test room", friends[0].Room)
+	}
+	if friends[1].Room != nil {
+		t.Errorf("bob is in %+v, want no room", friends[1].Room)
+	}
+}
+
+func TestFriendRequests(t *testing.T) {
+	var method string
+	var body map[string]int
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method + " " + r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+		writeEnvelope(w, "ok", []interface{}{})
+	})
+
+	if err := plug.AcceptFriendRequest(2); err != nil {
+		t.Fatal(err)
+	}
+	if method != "POST /_"+FriendsEndpoint || body["id"] != 2 {
+		t.Errorf("sent %s with %v, want a POST for user 2", method, body)
+	}
+
+	if err := plug.IgnoreFriendRequest(3); err != nil {
+		t.Fatal(err)
+	}
+	if method != "PUT /_"+FriendIgnoreEndpoint || body["id"] != 3 {
+		t.Errorf("sent %s with %v, want a PUT for user 3", method, body)
+	}
+}
+
+func TestFriendRequestEvents(t *testing.T) {
+	plug := &PlugDJ{config: &Config{}, Log: log.New(), eventFuncs: make(map[Event]ProcessPayloadFunc), Room: &Room{}}
+	plug.Room.SetUsers([]User{{ID: 2, Username: "alice"}})
+	requests := listenFor(plug, FriendRequestEvent)
+	joins := listenFor(plug, FollowJoinEvent)
+
+	handleAction_friendRequest(plug, json.RawMessage(`"alice"`))
+	if payload := nextPayload(t, requests).(FriendRequestPayload); payload.User.ID != 2 {
+		t.Errorf("friend request from %+v, want alice", payload.User)
+	}
+
+	// people not in the room still have a name
+	handleAction_friendRequest(plug, json.RawMessage(`"carol"`))
+	if payload := nextPayload(t, requests).(FriendRequestPayload); payload.User.Username != "carol" {
+		t.Errorf("friend request from %+v, want carol", payload.User)
+	}
+
+	handleAction_followJoin(plug, json.RawMessage(`{"id":4,"username":"dave"}`))
+	if payload := nextPayload(t, joins).(FollowJoinPayload); payload.ID != 4 {
+		t.Errorf("follow join of %+v, want dave", payload.User)
+	}
+}
@@ -0,0 +1,125 @@
+package plugapi
+
+import (
+	"github.com/gorilla/websocket"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Defaults for Config.HeartbeatTimeout and Config.PingInterval
+const (
+	defaultHeartbeatTimeout = time.Minute
+	defaultPingInterval     = 30 * time.Second
+)
+
+// Stats are statistics about our socket connection
+type Stats struct {
+	Latency       time.Duration // round trip time of our last ping
+	LastHeartbeat time.Time     // when plug last sent us an "h"
+	LastFrame     time.Time     // when plug last sent us anything
+	FramesIn      int           // frames received, heartbeats included
+	FramesOut     int           // frames sent
+	Reconnects    int           // times we got our socket back
+}
+
+// Stats returns statistics about our socket connection
+func (plug *PlugDJ) Stats() Stats {
+	plug.statsLock.RLock()
+	defer plug.statsLock.RUnlock()
+
+	return plug.stats
+}
+
+func (plug *PlugDJ) updateStats(fn func(stats *Stats)) {
+	plug.statsLock.Lock()
+	defer plug.statsLock.Unlock()
+
+	fn(&plug.stats)
+}
+
+// heartbeatTimeout is how long the socket may go quiet, zero means forever
+func (plug *PlugDJ) heartbeatTimeout() time.Duration {
+	switch timeout := plug.config.HeartbeatTimeout; {
+	case timeout < 0:
+		return 0
+	case timeout == 0:
+		return defaultHeartbeatTimeout
+	default:
+		return timeout
+	}
+}
+
+// extendDeadline gives the socket another heartbeatTimeout to
+// send us something before we decide that it has gone away
+func (plug *PlugDJ) extendDeadline(wss *websocket.Conn) {
+	if timeout := plug.heartbeatTimeout(); timeout > 0 {
+		wss.SetReadDeadline(time.Now().Add(timeout))
+	}
+}
+
+// startHeartbeat sets up the read deadline and our pings for a new socket
+func (plug *PlugDJ) startHeartbeat(wss *websocket.Conn, closer chan struct{}) {
+	plug.extendDeadline(wss)
+
+	// our pings carry the time they were sent,
+	// so the pong tells us the round trip time
+	wss.SetPongHandler(func(appData string) error {
+		plug.extendDeadline(wss)
+
+		sent, err := strconv.ParseInt(appData, 10, 64)
+		if err != nil {
+			return nil // not one of ours
+		}
+
+		latency := time.Since(time.Unix(0, sent))
+		plug.updateStats(func(stats *Stats) { stats.Latency = latency })
+		return nil
+	})
+
+	interval := plug.config.PingInterval
+	if interval < 0 {
+		return
+	} else if interval == 0 {
+		interval = defaultPingInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-closer:
+				return
+			case <-ticker.C:
+			}
+
+			now := time.Now()
+			data := []byte(strconv.FormatInt(now.UnixNano(), 10))
+			if err := wss.WriteControl(websocket.PingMessage, data, now.Add(10*time.Second)); err != nil {
+				plug.Log.WithField("error", err).Debugln("could not ping socket")
+			}
+		}
+	}()
+}
+
+// receivedFrame is called for every frame we read from the socket
+func (plug *PlugDJ) receivedFrame(wss *websocket.Conn, data []byte) {
+	plug.extendDeadline(wss)
+
+	now := time.Now()
+	plug.updateStats(func(stats *Stats) {
+		stats.FramesIn++
+		stats.LastFrame = now
+		if isHeartbeat(data) {
+			stats.LastHeartbeat = now
+		}
+	})
+}
+
+// isTimeout checks if a read failed because of our read deadline
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,69 @@
+package plugapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatTimeout(t *testing.T) {
+	tests := []struct {
+		config time.Duration
+		want   time.Duration
+	}{
+		{0, defaultHeartbeatTimeout},
+		{-1, 0},
+		{10 * time.Second, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		plug := &PlugDJ{config: &Config{HeartbeatTimeout: test.config}}
+		if got := plug.heartbeatTimeout(); got != test.want {
+			t.Errorf("heartbeatTimeout with %s = %s, want %s", test.config, got, test.want)
+		}
+	}
+}
+
+func TestReceivedFrame(t *testing.T) {
+	// without a timeout there is no deadline to extend on the socket
+	plug := &PlugDJ{config: &Config{HeartbeatTimeout: -1}}
+
+	before := time.Now()
+	plug.receivedFrame(nil, []byte("h"))
+	stats := plug.Stats()
+	if stats.FramesIn != 1 || stats.LastHeartbeat.Before(before) || !stats.LastFrame.Equal(stats.LastHeartbeat) {
+		t.Errorf("stats = %+v, want a heartbeat counted as a frame", stats)
+	}
+
+	heartbeat := stats.LastHeartbeat
+	time.Sleep(time.Millisecond)
+	plug.receivedFrame(nil, []byte(`[{"a":"chat"}]`))
+	stats = plug.Stats()
+	if stats.FramesIn != 2 || !stats.LastHeartbeat.Equal(heartbeat) || !stats.LastFrame.After(heartbeat) {
+		t.Errorf("stats = %+v, want a frame that isn't a heartbeat", stats)
+	}
+}
+
+// timeoutError is what reading from a socket past its deadline returns
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTimeout(t *testing.T) {
+	if !isTimeout(timeoutError{}) {
+		t.Error("a read past the deadline isn't a timeout")
+	}
+	if isTimeout(errors.New("closed")) || isTimeout(nil) {
+		t.Error("other errors are timeouts")
+	}
+}
+
+func TestIsHeartbeat(t *testing.T) {
+	for data, want := range map[string]bool{"h": true, "hh": false, "": false, `["h"]`: false} {
+		if got := isHeartbeat([]byte(data)); got != want {
+			t.Errorf("isHeartbeat(%q) = %t, want %t", data, got, want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package plugapi
+
+import (
+	"time"
+)
+
+// How many plays we keep in History, unless Config.HistorySize says
+// otherwise. This is also how many plays plug gives us at a time.
+const defaultHistorySize = 50
+
+// HistoryQuery filters the history of the room. Zero values don't filter.
+type HistoryQuery struct {
+	UserID int       // only plays by this DJ
+	CID    string    // only plays of this media
+	Since  time.Time // only plays started at or after this
+	Until  time.Time // only plays started before this
+
+	Offset int // skip this many matching plays
+	Limit  int // return at most this many matching plays
+}
+
+func (q HistoryQuery) matches(item HistoryItem) bool {
+	switch {
+	case q.UserID != 0 && item.User.ID != q.UserID:
+		return false
+	case q.CID != "" && item.Media.CID != q.CID:
+		return false
+	case !q.Since.IsZero() && item.Timestamp.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !item.Timestamp.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// QueryHistory returns the plays in the history of the room
+// matching the query, the most recent first. If the plays we keep
+// ourselves (see Config.HistorySize) can't fill the page, and plug
+// may know of plays that we don't, the history is fetched from plug.
+func (plug *PlugDJ) QueryHistory(q HistoryQuery) ([]HistoryItem, error) {
+	plug.historyLock.RLock()
+	results := q.find(plug.History)
+	complete := plug.historyComplete()
+	plug.historyLock.RUnlock()
+
+	if complete || (q.Limit > 0 && len(results) == q.Limit) {
+		return results, nil
+	}
+
+	var history []HistoryItem
+	if err := plug.GetData(HistoryEndpoint, &history, nil); err != nil {
+		return nil, err
+	}
+	return q.find(history), nil
+}
+
+// find returns the page of plays in history matching the query
+func (q HistoryQuery) find(history []HistoryItem) []HistoryItem {
+	var results []HistoryItem
+	skipped := 0
+	for _, item := range history {
+		if !q.matches(item) {
+			continue
+		}
+
+		if skipped < q.Offset {
+			skipped++
+			continue
+		}
+
+		results = append(results, item)
+		if q.Limit > 0 && len(results) == q.Limit {
+			break
+		}
+	}
+	return results
+}
+
+// historyComplete checks if History has every play plug would give us.
+// It doesn't if we never got the history from plug, or if we have since
+// dropped plays that plug still has. plug.historyLock must be locked.
+func (plug *PlugDJ) historyComplete() bool {
+	if !plug.historyFetched {
+		return false
+	}
+
+	size := plug.historySize()
+	return size >= defaultHistorySize || len(plug.History) < size
+}
+
+// historySize is how many plays we keep in History
+func (plug *PlugDJ) historySize() int {
+	if size := plug.config.HistorySize; size > 0 {
+		return size
+	}
+	return defaultHistorySize
+}
+
+// PlayedRecently checks if the media was played in the last plays
+// plays or the last within duration, returning the most recent play
+// if so. Zero for either means that it doesn't limit the search.
+func (plug *PlugDJ) PlayedRecently(cid string, plays int, within time.Duration) (*HistoryItem, bool) {
+	plug.historyLock.RLock()
+	defer plug.historyLock.RUnlock()
+
+	since := time.Time{}
+	if within > 0 {
+		since = plug.ServerNow().Add(-within)
+	}
+
+	for i, item := range plug.History {
+		if plays > 0 && i >= plays {
+			break
+		}
+
+		if !since.IsZero() && item.Timestamp.Before(since) {
+			break
+		}
+
+		if item.Media.CID == cid {
+			found := item
+			return &found, true
+		}
+	}
+	return nil, false
+}
+
+// RefreshHistory fetches the history of the room from plug,
+// replacing the history we have been keeping ourselves
+func (plug *PlugDJ) RefreshHistory() error {
+	var history []HistoryItem
+	if err := plug.GetData(HistoryEndpoint, &history, nil); err != nil {
+		return err
+	}
+
+	plug.historyLock.Lock()
+	plug.History = history
+	plug.historyFetched = true
+	plug.historyLock.Unlock()
+	return nil
+}
+
+// addHistory adds a finished play to the front of our history
+func (plug *PlugDJ) addHistory(playback *Playback, dj *User, score PlayScore) {
+	if playback == nil || playback.HistoryID == "" {
+		return
+	}
+
+	item := HistoryItem{
+		ID:        playback.HistoryID,
+		Media:     playback.Media,
+		Score:     score,
+		Timestamp: playback.StartTime,
+	}
+
+	plug.Room.RLock()
+	item.Room.Name = plug.Room.Meta.Name
+	item.Room.Slug = plug.Room.Meta.Slug
+	plug.Room.RUnlock()
+
+	if dj != nil {
+		item.User.ID = dj.ID
+		item.User.Username = dj.Username
+	}
+
+	size := plug.historySize()
+
+	plug.historyLock.Lock()
+	defer plug.historyLock.Unlock()
+
+	// plug may have given it to us already (the ID of
+	// a history item is the HistoryID of the playback)
+	for _, existing := range plug.History {
+		if existing.ID == item.ID {
+			return
+		}
+	}
+
+	plug.History = append([]HistoryItem{item}, plug.History...)
+	if len(plug.History) > size {
+		plug.History = plug.History[:size]
+	}
+}
@@ -0,0 +1,175 @@
+package plugapi
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newHistoryPlug returns a session with plays one minute apart, the most
+// recent first. Alice (2) played the even ones, bob (3) the odd ones.
+// They are all the plays plug has.
+func newHistoryPlug(plays int) *PlugDJ {
+	plug := newPlaybackPlug(Config{})
+	plug.History = historyItems(plays)
+	plug.historyFetched = true
+	return plug
+}
+
+func historyItems(plays int) (items []HistoryItem) {
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := plays - 1; i >= 0; i-- {
+		item := HistoryItem{
+			ID:        strconv.Itoa(i),
+			Media:     Media{CID: "cid" + strconv.Itoa(i%3)},
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+		}
+		item.User.ID = 2 + i%2
+		items = append(items, item)
+	}
+	return
+}
+
+func historyIDs(items []HistoryItem) (ids []string) {
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return
+}
+
+func TestQueryHistory(t *testing.T) {
+	plug := newHistoryPlug(10)
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query HistoryQuery
+		want  string
+	}{
+		{"everything", HistoryQuery{}, "[9 8 7 6 5 4 3 2 1 0]"},
+		{"first page", HistoryQuery{Limit: 3}, "[9 8 7]"},
+		{"second page", HistoryQuery{Offset: 3, Limit: 3}, "[6 5 4]"},
+		{"last page", HistoryQuery{Offset: 9, Limit: 3}, "[0]"},
+		{"past the end", HistoryQuery{Offset: 10, Limit: 3}, "[]"},
+		{"by DJ", HistoryQuery{UserID: 3}, "[9 7 5 3 1]"},
+		{"by DJ paged", HistoryQuery{UserID: 3, Offset: 1, Limit: 2}, "[7 5]"},
+		{"by media", HistoryQuery{CID: "cid0"}, "[9 6 3 0]"},
+		{"since is inclusive", HistoryQuery{Since: start.Add(8 * time.Minute)}, "[9 8]"},
+		{"until is exclusive", HistoryQuery{Until: start.Add(2 * time.Minute)}, "[1 0]"},
+	}
+
+	for _, test := range tests {
+		items, err := plug.QueryHistory(test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if s := "[" + joinIDs(historyIDs(items)) + "]"; s != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, s, test.want)
+		}
+	}
+}
+
+func TestQueryHistoryFromPlug(t *testing.T) {
+	var requests int32
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/_"+HistoryEndpoint {
+			t.Errorf("path = %s, want %s", r.URL.Path, "/_"+HistoryEndpoint)
+		}
+		writeEnvelope(w, "ok", historyItems(10))
+	})
+	plug.config.HistorySize = 4
+	plug.History = historyItems(10)[:4]
+	plug.historyFetched = true
+
+	tests := []struct {
+		name     string
+		query    HistoryQuery
+		want     string
+		requests int32
+	}{
+		{"kept ourselves", HistoryQuery{Limit: 4}, "[9 8 7 6]", 0},
+		{"past what we keep", HistoryQuery{Offset: 3, Limit: 3}, "[6 5 4]", 1},
+		{"everything", HistoryQuery{}, "[9 8 7 6 5 4 3 2 1 0]", 1},
+	}
+
+	for _, test := range tests {
+		atomic.StoreInt32(&requests, 0)
+		items, err := plug.QueryHistory(test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if s := "[" + joinIDs(historyIDs(items)) + "]"; s != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, s, test.want)
+		}
+		if n := atomic.LoadInt32(&requests); n != test.requests {
+			t.Errorf("%s: %d requests to plug, want %d", test.name, n, test.requests)
+		}
+	}
+
+	// without plug's history we can't know anything is complete
+	plug.History = nil
+	plug.historyFetched = false
+	plug.config.HistorySize = 0
+	if items, err := plug.QueryHistory(HistoryQuery{UserID: 2, Limit: 2}); err != nil || joinIDs(historyIDs(items)) != "8 6" {
+		t.Errorf("query before fetching = %v, %v, want 8 and 6", historyIDs(items), err)
+	}
+}
+
+func joinIDs(ids []string) (s string) {
+	for i, id := range ids {
+		if i > 0 {
+			s += " "
+		}
+		s += id
+	}
+	return
+}
+
+func TestPlayedRecently(t *testing.T) {
+	plug := newHistoryPlug(10)
+
+	if item, ok := plug.PlayedRecently("cid1", 0, 0); !ok || item.ID != "7" {
+		t.Errorf("PlayedRecently(cid1) = %v, %t, want play 7", item, ok)
+	}
+	if _, ok := plug.PlayedRecently("cid1", 2, 0); ok {
+		t.Error("cid1 was played in the last 2 plays")
+	}
+	if _, ok := plug.PlayedRecently("nope", 0, 0); ok {
+		t.Error("media that was never played was played")
+	}
+
+	// the plays are all years ago
+	if _, ok := plug.PlayedRecently("cid0", 0, time.Hour); ok {
+		t.Error("cid0 was played in the last hour")
+	}
+}
+
+func TestAddHistory(t *testing.T) {
+	plug := newHistoryPlug(3)
+	plug.config.HistorySize = 3
+	dj := &User{ID: 2, Username: "alice"}
+
+	plug.addHistory(&Playback{HistoryID: "new", Media: Media{CID: "x"}}, dj, PlayScore{})
+	if got := joinIDs(historyIDs(plug.History)); got != "new 2 1" {
+		t.Errorf("history = %s, want the new play first and only 3 kept", got)
+	}
+	if plug.History[0].User.Username != "alice" {
+		t.Errorf("new play by %+v, want alice", plug.History[0].User)
+	}
+
+	// plug may have told us about the play already
+	plug.addHistory(&Playback{HistoryID: "2"}, dj, PlayScore{})
+	if got := joinIDs(historyIDs(plug.History)); got != "new 2 1" {
+		t.Errorf("history = %s, want the play only once", got)
+	}
+
+	// nothing played, nothing to add
+	plug.addHistory(nil, dj, PlayScore{})
+	plug.addHistory(&Playback{}, dj, PlayScore{})
+	if len(plug.History) != 3 {
+		t.Errorf("history = %s, want nothing added", joinIDs(historyIDs(plug.History)))
+	}
+}
@@ -0,0 +1,43 @@
+package plugapi
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter spaces out calls so that at most
+// limit.Requests happen every limit.Period,
+// across all its users
+type limiter struct {
+	sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newLimiter returns nil (no limit) for a zero RateLimit
+func newLimiter(limit RateLimit) *limiter {
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return nil
+	}
+
+	return &limiter{interval: limit.Period / time.Duration(limit.Requests)}
+}
+
+// wait blocks until we are allowed to make another call.
+// A nil limiter never blocks.
+func (l *limiter) wait() {
+	if l == nil {
+		return
+	}
+
+	l.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.Unlock()
+
+	time.Sleep(delay)
+}
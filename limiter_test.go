@@ -0,0 +1,35 @@
+package plugapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewLimiter(t *testing.T) {
+	if newLimiter(RateLimit{}) != nil || newLimiter(RateLimit{Requests: 4}) != nil {
+		t.Error("a RateLimit without requests or a period should have no limiter")
+	}
+
+	l := newLimiter(RateLimit{Requests: 4, Period: time.Second})
+	if l.interval != 250*time.Millisecond {
+		t.Errorf("interval = %s, want 250ms", l.interval)
+	}
+
+	// a nil limiter never blocks
+	var none *limiter
+	none.wait()
+}
+
+func TestLimiterSpacesCalls(t *testing.T) {
+	l := newLimiter(RateLimit{Requests: 20, Period: time.Second})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		l.wait()
+	}
+
+	// the first call goes straight away, the others 50ms apart
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("three calls took %s, want at least 100ms", elapsed)
+	}
+}
@@ -0,0 +1,281 @@
+package plugapi
+
+import (
+	"errors"
+	log "github.com/Sirupsen/logrus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ManagerConfig is the configuration shared by every session of a Manager
+type ManagerConfig struct {
+	Log       *log.Logger
+	Transport http.RoundTripper // http.DefaultTransport is used if nil
+
+	// Limits REST requests and chat messages of all
+	// sessions together. The zero value means no limit.
+	RateLimit RateLimit
+
+	// How often sessions that have given up on reconnecting are
+	// joined to their room again. Zero means every 30 seconds.
+	SuperviseInterval time.Duration
+}
+
+// Manager runs many PlugDJ sessions (one per account) side by side
+type Manager struct {
+	config  ManagerConfig
+	limiter *limiter
+
+	sessions map[string]*managedSession
+	lock     sync.RWMutex
+
+	eventFuncs map[Event]ManagedPayloadFunc
+	eventLock  sync.RWMutex
+
+	closer    chan struct{}
+	closeOnce sync.Once
+	closed    bool // guarded by lock
+}
+
+// managedSession is a session and the room we want it to be in.
+// plug is nil while Add is still logging the session in.
+type managedSession struct {
+	plug *PlugDJ
+	slug string
+}
+
+// ManagedPayload is a payload from one of the sessions of a Manager
+type ManagedPayload struct {
+	Account string // the account given to Manager.Add
+	Room    string // slug of the room the session was in
+	Event   Event
+	Payload interface{}
+}
+
+// ManagedPayloadFunc is the function called for events of a Manager
+type ManagedPayloadFunc func(plug *PlugDJ, payload ManagedPayload)
+
+// NewManager returns a Manager without any sessions
+func NewManager(config ManagerConfig) *Manager {
+	if config.Log == nil {
+		config.Log = log.New()
+	}
+
+	if config.Transport == nil {
+		config.Transport = http.DefaultTransport
+	}
+
+	if config.SuperviseInterval == 0 {
+		config.SuperviseInterval = 30 * time.Second
+	}
+
+	m := &Manager{
+		config:     config,
+		limiter:    newLimiter(config.RateLimit),
+		sessions:   make(map[string]*managedSession),
+		eventFuncs: make(map[Event]ManagedPayloadFunc),
+		closer:     make(chan struct{}),
+	}
+
+	go m.supervise()
+	return m
+}
+
+// Add logs in a new session for the account. The logger, transport
+// and rate limit of the Manager replace those in the config.
+func (m *Manager) Add(account string, config Config) (*PlugDJ, error) {
+	// reserve the account so that nobody else logs it in meanwhile
+	reserved := &managedSession{}
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return nil, errors.New("plugapi: manager closed")
+	}
+	if _, exists := m.sessions[account]; exists {
+		m.lock.Unlock()
+		return nil, errors.New("plugapi: account already managed")
+	}
+	m.sessions[account] = reserved
+	m.lock.Unlock()
+
+	config.Log = m.config.Log
+	config.Transport = m.config.Transport
+	config.limiter = m.limiter
+	config.eventHook = func(plug *PlugDJ, event Event, payload interface{}) {
+		m.emitEvent(account, plug, event, payload)
+	}
+
+	plug, err := New(config)
+	if err != nil {
+		m.lock.Lock()
+		if m.sessions[account] == reserved {
+			delete(m.sessions, account)
+		}
+		m.lock.Unlock()
+		return nil, err
+	}
+
+	// we may have been closed or removed while logging in
+	m.lock.Lock()
+	if m.closed || m.sessions[account] != reserved {
+		m.lock.Unlock()
+		plug.Close()
+		return nil, errors.New("plugapi: account removed while logging in")
+	}
+	reserved.plug = plug
+	m.lock.Unlock()
+
+	return plug, nil
+}
+
+// Join makes the session for the account join the room, and keeps it
+// there for as long as it is managed, unless it leaves with LeaveRoom
+func (m *Manager) Join(account, slug string) error {
+	m.lock.Lock()
+	var plug *PlugDJ
+	session, ok := m.sessions[account]
+	if ok && session.plug != nil {
+		plug = session.plug
+		session.slug = slug
+	}
+	m.lock.Unlock()
+
+	if !ok {
+		return errors.New("plugapi: account not managed")
+	} else if plug == nil {
+		return errors.New("plugapi: account still logging in")
+	}
+
+	return plug.JoinRoom(slug)
+}
+
+// Session returns the session for the account, or nil
+func (m *Manager) Session(account string) *PlugDJ {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if session, ok := m.sessions[account]; ok {
+		return session.plug // nil while still logging in
+	}
+	return nil
+}
+
+// Accounts returns the accounts of all sessions
+func (m *Manager) Accounts() (accounts []string) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	for account := range m.sessions {
+		accounts = append(accounts, account)
+	}
+	return
+}
+
+// Remove closes the session for the account and stops managing it
+func (m *Manager) Remove(account string) {
+	m.lock.Lock()
+	session, ok := m.sessions[account]
+	delete(m.sessions, account)
+	m.lock.Unlock()
+
+	// sessions still logging in are closed by Add
+	if ok && session.plug != nil {
+		session.plug.Close()
+	}
+}
+
+// Close closes all sessions at the same time, returning once every
+// one of them has closed. Sessions can't be added afterwards.
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() { close(m.closer) })
+
+	m.lock.Lock()
+	m.closed = true
+	sessions := m.sessions
+	m.sessions = make(map[string]*managedSession)
+	m.lock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		// sessions still logging in are closed by Add
+		if session.plug == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(plug *PlugDJ) {
+			defer wg.Done()
+			plug.Close()
+		}(session.plug)
+	}
+	wg.Wait()
+}
+
+// RegisterEvents registers the function to call when the specified event(s)
+// are encountered by any session. This is independent from the handlers
+// registered on the sessions themselves.
+func (m *Manager) RegisterEvents(fn ManagedPayloadFunc, events ...Event) {
+	m.eventLock.Lock()
+	defer m.eventLock.Unlock()
+
+	for _, event := range events {
+		m.eventFuncs[event] = fn
+	}
+}
+
+func (m *Manager) emitEvent(account string, plug *PlugDJ, event Event, payload interface{}) {
+	m.eventLock.RLock()
+	fn := m.eventFuncs[event]
+	m.eventLock.RUnlock()
+
+	if fn == nil {
+		return
+	}
+
+	plug.Room.RLock()
+	room := plug.Room.Meta.Slug
+	plug.Room.RUnlock()
+
+	fn(plug, ManagedPayload{
+		Account: account,
+		Room:    room,
+		Event:   event,
+		Payload: payload,
+	})
+}
+
+// supervise rejoins sessions that have lost their room and given up
+// on getting it back, e.g. after plug's maintenance has ended.
+// Sessions that left their room with LeaveRoom are left alone.
+func (m *Manager) supervise() {
+	ticker := time.NewTicker(m.config.SuperviseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.closer:
+			return
+		case <-ticker.C:
+		}
+
+		for _, session := range m.lostSessions() {
+			if err := session.plug.JoinRoom(session.slug); err != nil {
+				m.config.Log.WithField("slug", session.slug).Warnln("could not rejoin room", err)
+			}
+		}
+	}
+}
+
+// lostSessions returns the sessions that should be in a room but aren't
+func (m *Manager) lostSessions() (lost []managedSession) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	for _, session := range m.sessions {
+		if session.plug != nil && session.slug != "" && session.plug.State() == DisconnectedState && !session.plug.hasLeft() {
+			lost = append(lost, *session)
+		}
+	}
+	return
+}
@@ -0,0 +1,91 @@
+package plugapi
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"testing"
+	"time"
+)
+
+func TestManagerCloseTwice(t *testing.T) {
+	m := NewManager(ManagerConfig{Log: log.New()})
+	m.Close()
+	m.Close()
+
+	if _, err := m.Add("bot", Config{Email: "a@b.c", Password: "x"}); err == nil {
+		t.Error("Add after Close succeeded")
+	}
+}
+
+func TestManagerAddReservesAccount(t *testing.T) {
+	m := NewManager(ManagerConfig{Log: log.New()})
+	defer m.Close()
+
+	// as if another Add for the account is still logging in
+	m.sessions["bot"] = &managedSession{}
+
+	if _, err := m.Add("bot", Config{Email: "a@b.c", Password: "x"}); err == nil {
+		t.Error("Add of an account being logged in succeeded")
+	}
+	if err := m.Join("bot", "room"); err == nil {
+		t.Error("Join of an account being logged in succeeded")
+	}
+	if m.Session("bot") != nil {
+		t.Error("Session of an account being logged in isn't nil")
+	}
+}
+
+func TestManagerAddFailureFreesAccount(t *testing.T) {
+	m := NewManager(ManagerConfig{Log: log.New(), Transport: offlineTransport{}})
+	defer m.Close()
+
+	if _, err := m.Add("bot", Config{Email: "a@b.c", Password: "x"}); err == nil {
+		t.Fatal("Add without a connection succeeded")
+	}
+	if accounts := m.Accounts(); len(accounts) != 0 {
+		t.Errorf("accounts = %v after a failed Add, want none", accounts)
+	}
+}
+
+func TestManagerSupervisesLostSessions(t *testing.T) {
+	m := NewManager(ManagerConfig{Log: log.New()})
+	defer m.Close()
+
+	lost := newPlug(Config{Log: log.New()})
+	left := newPlug(Config{Log: log.New()})
+	left.setLeft(true)
+	connected := newPlug(Config{Log: log.New()})
+	connected.setState(ConnectedState)
+
+	m.sessions["lost"] = &managedSession{plug: lost, slug: "room"}
+	m.sessions["left"] = &managedSession{plug: left, slug: "room"}
+	m.sessions["connected"] = &managedSession{plug: connected, slug: "room"}
+	m.sessions["never joined"] = &managedSession{plug: newPlug(Config{Log: log.New()})}
+
+	sessions := m.lostSessions()
+	if len(sessions) != 1 || sessions[0].plug != lost {
+		t.Errorf("lost sessions = %+v, want only the lost one", sessions)
+	}
+}
+
+func TestManagerEventHook(t *testing.T) {
+	m := NewManager(ManagerConfig{Log: log.New()})
+	defer m.Close()
+
+	got := make(chan ManagedPayload, 1)
+	m.RegisterEvents(func(_ *PlugDJ, payload ManagedPayload) { got <- payload }, ChatEvent)
+
+	// the hook is set before the session exists, so nothing is missed
+	plug := newPlug(Config{Log: log.New(), eventHook: func(plug *PlugDJ, event Event, payload interface{}) {
+		m.emitEvent("bot", plug, event, payload)
+	}})
+	plug.emitEvent(ChatEvent, "hi")
+
+	select {
+	case payload := <-got:
+		if payload.Account != "bot" || payload.Event != ChatEvent || payload.Payload != "hi" {
+			t.Errorf("payload = %+v, want the chat from bot", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+}
@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	log "github.com/Sirupsen/logrus"
+	"html"
 	"strconv"
-	"strings"
+	"time"
 )
 
 // Signature of all action handlers
@@ -33,19 +34,41 @@ func init() {
 	// where ACTIONNAME is the exact
 	// string found in socketMessage.Action
 	actions["ack"] = handleAction_ack
+	actions["advance"] = handleAction_advance
 	actions["chat"] = handleAction_chat
+	actions["djListUpdate"] = handleAction_djListUpdate
+	actions["earn"] = handleAction_earn
+	actions["followJoin"] = handleAction_followJoin
+	actions["friendRequest"] = handleAction_friendRequest
+	actions["gifted"] = handleAction_gifted
+	actions["grab"] = handleAction_grab
+	actions["killSession"] = handleAction_killSession
+	actions["plugMaintenance"] = handleAction_plugMaintenance
+	actions["plugMaintenanceAlert"] = handleAction_plugMaintenanceAlert
+	actions["sessionClose"] = handleAction_sessionClose
 	actions["userLeave"] = handleAction_userLeave
 	actions["userJoin"] = handleAction_userJoin
+	actions["vote"] = handleAction_vote
 
-	// Ignoring
-	actions["chatDelete"] = handleAction_IGNORER
-	actions["earn"] = handleAction_IGNORER
+	actions["chatDelete"] = handleAction_chatDelete
+	actions["modAddDJ"] = handleAction_modAddDJ
+	actions["modBan"] = handleAction_modBan
+	actions["modMoveDJ"] = handleAction_modMoveDJ
+	actions["modMute"] = handleAction_modMute
+	actions["modRemoveDJ"] = handleAction_modRemoveDJ
+	actions["modSkip"] = handleAction_modSkip
+	actions["modStaff"] = handleAction_modStaff
 }
 
 // Base action that executes the correct handler
 // or do some debug outputs if the handler
 // does not exist for the given message.
 func handleAction(plug *PlugDJ, msg socketMessage) {
+	// every message tells us plug's time, to the second
+	if msg.Time > 0 {
+		plug.clock.observe(time.Unix(msg.Time, 0))
+	}
+
 	handler, ok := actions[msg.Action]
 	if ok {
 		// a handler exists, lets call it,
@@ -64,7 +87,12 @@ func handleAction(plug *PlugDJ, msg socketMessage) {
 func handleAction_IGNORER(_ *PlugDJ, _ json.RawMessage) {}
 
 func handleAction_ack(plug *PlugDJ, msg json.RawMessage) {
-	ack := plug.ack
+	_, ack := plug.connection()
+
+	// nobody is waiting for it, e.g. when replaying a recording
+	if ack == nil {
+		return
+	}
 
 	var param string
 	err := json.Unmarshal(msg, &param)
@@ -96,28 +124,50 @@ func handleAction_chat(plug *PlugDJ, msg json.RawMessage) {
 	json.Unmarshal(msg, &raw)
 
 	// Don't readvertise our own chat messages
-	if raw.UserID == plug.User.ID {
+	self := plug.Me().User
+	if raw.UserID == self.ID {
+		plug.deleteOwnMessageLater(html.UnescapeString(raw.Message), raw.MessageID)
 		return
 	}
 
+	now := time.Now()
+	plug.updateActivity(raw.UserID, func(activity *Activity) { activity.LastChat = now })
+
 	user := plug.Room.getUser(raw.UserID)
+	parsed := parseChat(plug.Room, &self, raw.Message)
 
 	payload := ChatPayload{
-		Message:   raw.Message,
+		Message:   parsed.message,
 		MessageID: raw.MessageID,
 		User:      user,
-		// Type is added below
+		Type:      RegularChatMessage,
+		Mentions:  parsed.mentions,
+		Specials:  parsed.specials,
+		Emotes:    parsed.emotes,
+		Links:     parsed.links,
 	}
 
-	// If contains "/me" or "/em" at the front, make the type an EmoteChatMessage. Make it Regular otherwise.
-	if strings.Index(raw.Message, "/me") == 0 || strings.Index(raw.Message, "/em") == 0 {
+	if parsed.emote {
 		payload.Type = EmoteChatMessage
-		payload.Message = raw.Message[3:]
-	} else {
-		payload.Type = RegularChatMessage
+	}
+
+	for _, u := range parsed.mentions {
+		if u.ID == self.ID {
+			payload.MentionsMe = true
+			break
+		}
+	}
+
+	// messages that broke the rules are gone, nobody should answer them
+	if plug.filterChat(payload, raw.UserID) {
+		return
 	}
 
 	plug.emitEvent(ChatEvent, payload)
+
+	if plug.isSpotCommand(payload.Message) {
+		plug.replySpot(payload)
+	}
 }
 
 func handleAction_userLeave(plug *PlugDJ, msg json.RawMessage) {
@@ -127,12 +177,20 @@ func handleAction_userLeave(plug *PlugDJ, msg json.RawMessage) {
 		plug.Log.Warnln("could not unmarshal user leave", err)
 	}
 
+	plug.saveSpot(uid)
+
 	user := plug.Room.removeUser(uid)
 	if user == nil {
+		if _, ok := plug.departed.get(uid); ok {
+			plug.Log.WithField("uid", uid).Debugln("user left twice")
+			return
+		}
 		plug.Log.WithField("uid", uid).Warnln("Non existent user tried to leave")
 		return
 	}
 
+	plug.forgetActivity(uid)
+
 	payload := UserLeavePayload{*user}
 	plug.Log.Debugln("emit leave")
 	plug.emitEvent(UserLeaveEvent, payload)
@@ -148,7 +206,387 @@ func handleAction_userJoin(plug *PlugDJ, msg json.RawMessage) {
 	plug.Room.addUser(u)
 	plug.Log.Debugln("user added")
 
+	now := time.Now()
+	plug.updateActivity(u.ID, func(activity *Activity) { activity.Joined = now })
+	plug.background(func() { plug.restoreSpot(u.ID) })
+
 	payload := UserJoinPayload{u}
 	plug.Log.Debugln("emit join")
 	plug.emitEvent(UserJoinEvent, payload)
 }
+
+func handleAction_vote(plug *PlugDJ, msg json.RawMessage) {
+	raw := struct {
+		UserID int `json:"i"`
+		Vote   int `json:"v"`
+	}{}
+	if err := json.Unmarshal(msg, &raw); err != nil {
+		plug.Log.Warnln("could not unmarshal vote", err)
+		return
+	}
+
+	now := time.Now()
+	plug.updateActivity(raw.UserID, func(activity *Activity) { activity.LastVote = now })
+	plug.Room.vote(raw.UserID, raw.Vote)
+
+	plug.emitEvent(VoteEvent, VotePayload{
+		User: plug.Room.getUser(raw.UserID),
+		Vote: raw.Vote,
+	})
+}
+
+func handleAction_grab(plug *PlugDJ, msg json.RawMessage) {
+	var userID int
+	if err := json.Unmarshal(msg, &userID); err != nil {
+		plug.Log.Warnln("could not unmarshal grab", err)
+		return
+	}
+
+	plug.Room.grab(userID)
+	plug.emitEvent(GrabEvent, GrabPayload{User: plug.Room.getUser(userID)})
+}
+
+func handleAction_djListUpdate(plug *PlugDJ, msg json.RawMessage) {
+	var waiting []int
+	if err := json.Unmarshal(msg, &waiting); err != nil {
+		plug.Log.Warnln("could not unmarshal dj list update", err)
+		return
+	}
+
+	plug.updateWaitlist(waiting)
+	plug.emitEvent(DJListUpdateEvent, DJListUpdatePayload{plug.Room.getDJs()})
+}
+
+func handleAction_earn(plug *PlugDJ, msg json.RawMessage) {
+	payload := EarnPayload{}
+	if err := json.Unmarshal(msg, &payload); err != nil {
+		plug.Log.Warnln("could not unmarshal earn", err)
+		return
+	}
+
+	oldLevel := 0
+	plug.updateMe(func(me *Profile) {
+		oldLevel = me.Level
+		me.XP = payload.XP
+		me.PP = payload.PP
+		if payload.Level > 0 {
+			me.Level = payload.Level
+		}
+	})
+
+	plug.emitEvent(EarnEvent, payload)
+
+	// we only know we levelled up if we knew our level before
+	if oldLevel > 0 && payload.Level > oldLevel {
+		plug.emitEvent(LevelUpEvent, LevelUpPayload{oldLevel, payload.Level})
+	}
+}
+
+func handleAction_gifted(plug *PlugDJ, msg json.RawMessage) {
+	raw := struct {
+		Sender    string `json:"s"`
+		Recipient string `json:"r"`
+	}{}
+	if err := json.Unmarshal(msg, &raw); err != nil {
+		plug.Log.Warnln("could not unmarshal gifted", err)
+		return
+	}
+
+	plug.emitEvent(GiftedEvent, GiftedPayload{
+		Sender:    plug.userByName(raw.Sender),
+		Recipient: plug.userByName(raw.Recipient),
+	})
+}
+
+func handleAction_killSession(plug *PlugDJ, msg json.RawMessage) {
+	plug.setState(KilledState)
+	plug.emitEvent(KillSessionEvent, nil)
+}
+
+func handleAction_sessionClose(plug *PlugDJ, msg json.RawMessage) {
+	plug.setState(KilledState)
+	plug.emitEvent(SessionCloseEvent, nil)
+}
+
+func handleAction_plugMaintenance(plug *PlugDJ, msg json.RawMessage) {
+	plug.setState(MaintenanceState)
+	plug.emitEvent(MaintModeEvent, nil)
+}
+
+func handleAction_plugMaintenanceAlert(plug *PlugDJ, msg json.RawMessage) {
+	minutes := 0
+	if err := json.Unmarshal(msg, &minutes); err != nil {
+		plug.Log.Warnln("could not unmarshal maintenance alert", err)
+	}
+
+	plug.emitEvent(MaintModeAlertEvent, MaintModeAlertPayload{minutes})
+}
+
+func handleAction_friendRequest(plug *PlugDJ, msg json.RawMessage) {
+	username := ""
+	if err := json.Unmarshal(msg, &username); err != nil {
+		plug.Log.Warnln("could not unmarshal friend request", err)
+		return
+	}
+
+	plug.emitEvent(FriendRequestEvent, FriendRequestPayload{plug.userByName(username)})
+}
+
+func handleAction_followJoin(plug *PlugDJ, msg json.RawMessage) {
+	u := User{}
+	if err := json.Unmarshal(msg, &u); err != nil {
+		plug.Log.Warnln("could not unmarshal follow join", err)
+		return
+	}
+
+	plug.emitEvent(FollowJoinEvent, FollowJoinPayload{u})
+}
+
+func handleAction_advance(plug *PlugDJ, msg json.RawMessage) {
+	raw := struct {
+		CurrentDJ  int    `json:"c"`
+		DJs        []int  `json:"d"`
+		HistoryID  string `json:"h"`
+		Media      *Media `json:"m"`
+		PlaylistID int    `json:"p"`
+		StartTime  string `json:"t"`
+	}{}
+	if err := json.Unmarshal(msg, &raw); err != nil {
+		plug.Log.Warnln("could not unmarshal advance", err)
+		return
+	}
+
+	room := plug.Room
+	payload := AdvancePayload{}
+
+	// remember what was playing before we forget it
+	last := room.getDJ()
+	score := room.score()
+	room.RLock()
+	lastPlayback := room.Playback
+	room.RUnlock()
+
+	if last != nil && lastPlayback != nil {
+		payload.LastPlay = &struct {
+			DJ    *User
+			Media Media
+			Score PlayScore
+		}{DJ: last, Media: lastPlayback.Media, Score: score}
+	}
+	plug.addHistory(lastPlayback, last, score)
+
+	var playback *Playback
+	if raw.Media != nil && raw.CurrentDJ > 0 {
+		startTime, err := parseTimestamp(raw.StartTime)
+		if err != nil {
+			plug.Log.WithField("t", raw.StartTime).Warnln("could not parse advance start time")
+			startTime = plug.ServerNow()
+		}
+
+		playback = &Playback{
+			HistoryID:  raw.HistoryID,
+			Media:      *raw.Media,
+			PlaylistID: raw.PlaylistID,
+			StartTime:  startTime,
+		}
+	}
+
+	room.Lock()
+	room.Booth.CurrentDJ = raw.CurrentDJ
+	room.Playback = playback
+	room.votes, room.grabs, room.skipped = nil, nil, false
+	room.Unlock()
+
+	// everyone moved up a spot, including those that left
+	plug.updateWaitlist(raw.DJs)
+	plug.shiftSpots()
+
+	payload.CurrentDJ = room.getDJ()
+	payload.DJs = room.getDJs()
+	payload.Playback = playback
+
+	plug.scheduleTrackTimers(playback)
+	plug.emitEvent(AdvanceEvent, payload)
+
+	if playback != nil && len(plug.config.MediaPolicies) > 0 {
+		dj := payload.CurrentDJ
+		if dj == nil {
+			dj = &User{ID: raw.CurrentDJ}
+		}
+		plug.background(func() { plug.enforceMediaPolicies(playback, dj) })
+	}
+}
+
+// modAction is what plug sends for moderation actions,
+// not every action has every field
+type modAction struct {
+	Moderator   string          `json:"m"`
+	ModeratorID int             `json:"mi"`
+	Target      string          `json:"t"`
+	TargetID    int             `json:"i"`
+	Duration    json.RawMessage `json:"d"` // a string for mutes and bans
+	Reason      int             `json:"r"`
+	ChatID      string          `json:"c"` // chatDelete
+	Moved       string          `json:"u"` // modMoveDJ
+	From        int             `json:"o"` // modMoveDJ
+	To          int             `json:"n"` // modMoveDJ
+}
+
+// handleModeration audits a moderation action and sends its event.
+// Our own actions were audited when we took them, so they aren't again.
+func handleModeration(plug *PlugDJ, action string, event Event, raw modAction, entry AuditEntry) {
+	entry.Action = action
+	entry.Actor = plug.auditUser(raw.ModeratorID, raw.Moderator)
+	if entry.Target == (AuditUser{}) {
+		entry.Target = plug.auditUser(raw.TargetID, raw.Target)
+	}
+	if entry.Time.IsZero() {
+		entry.Time = plug.ServerNow()
+	}
+
+	if raw.ModeratorID != plug.Me().ID {
+		plug.audit(entry)
+	}
+
+	plug.emitEvent(event, ModerationPayload{entry})
+}
+
+// readModAction reads a moderation action, logging if it couldn't
+func readModAction(plug *PlugDJ, name string, msg json.RawMessage) (modAction, bool) {
+	var raw modAction
+	if err := json.Unmarshal(msg, &raw); err != nil {
+		plug.Log.WithField("action", name).Warnln("could not unmarshal moderation action", err)
+		return raw, false
+	}
+	return raw, true
+}
+
+func handleAction_chatDelete(plug *PlugDJ, msg json.RawMessage) {
+	raw, ok := readModAction(plug, "chatDelete", msg)
+	if !ok {
+		return
+	}
+
+	// users deleting their own messages aren't moderating
+	if raw.ModeratorID == 0 {
+		plug.emitEvent(ChatDeleteEvent, ModerationPayload{AuditEntry{
+			Time:   plug.ServerNow(),
+			Action: AuditDeleteChat,
+			Detail: raw.ChatID,
+		}})
+		return
+	}
+
+	handleModeration(plug, AuditDeleteChat, ChatDeleteEvent, raw, AuditEntry{Detail: raw.ChatID})
+}
+
+func handleAction_modAddDJ(plug *PlugDJ, msg json.RawMessage) {
+	if raw, ok := readModAction(plug, "modAddDJ", msg); ok {
+		handleModeration(plug, AuditAddDJ, ModerateAddDjEvent, raw, AuditEntry{})
+	}
+}
+
+func handleAction_modBan(plug *PlugDJ, msg json.RawMessage) {
+	raw, ok := readModAction(plug, "modBan", msg)
+	if !ok {
+		return
+	}
+
+	var duration BanDuration
+	json.Unmarshal(raw.Duration, &duration)
+
+	handleModeration(plug, AuditBan, ModerateBanEvent, raw, AuditEntry{
+		Duration: banLengths[duration],
+		Reason:   reasonText(banReasons, raw.Reason),
+	})
+}
+
+func handleAction_modMoveDJ(plug *PlugDJ, msg json.RawMessage) {
+	raw, ok := readModAction(plug, "modMoveDJ", msg)
+	if !ok {
+		return
+	}
+
+	handleModeration(plug, AuditMoveDJ, ModerateMoveDjEvent, raw, AuditEntry{
+		Target: plug.auditUser(0, raw.Moved),
+		Detail: "from " + strconv.Itoa(raw.From) + " to " + strconv.Itoa(raw.To),
+	})
+}
+
+func handleAction_modMute(plug *PlugDJ, msg json.RawMessage) {
+	raw, ok := readModAction(plug, "modMute", msg)
+	if !ok {
+		return
+	}
+
+	var duration MuteDuration
+	json.Unmarshal(raw.Duration, &duration)
+
+	// plug unmutes with a mute that lasts for "o"
+	if duration == "o" {
+		handleModeration(plug, AuditUnmute, ModerateMuteEvent, raw, AuditEntry{})
+		return
+	}
+
+	handleModeration(plug, AuditMute, ModerateMuteEvent, raw, AuditEntry{
+		Duration: muteLengths[duration],
+		Reason:   reasonText(muteReasons, raw.Reason),
+	})
+}
+
+func handleAction_modRemoveDJ(plug *PlugDJ, msg json.RawMessage) {
+	if raw, ok := readModAction(plug, "modRemoveDJ", msg); ok {
+		handleModeration(plug, AuditRemoveDJ, ModerateRemoveDjEvent, raw, AuditEntry{})
+	}
+}
+
+func handleAction_modSkip(plug *PlugDJ, msg json.RawMessage) {
+	raw, ok := readModAction(plug, "modSkip", msg)
+	if !ok {
+		return
+	}
+
+	// plug doesn't say who was skipped, and the advance after the skip
+	// may already have been handled, so we can't tell either
+	handleModeration(plug, AuditSkip, ModerateSkipEvent, raw, AuditEntry{})
+}
+
+func handleAction_modStaff(plug *PlugDJ, msg json.RawMessage) {
+	raw := struct {
+		Moderator   string `json:"m"`
+		ModeratorID int    `json:"mi"`
+		Users       []struct {
+			ID       int    `json:"i"`
+			Username string `json:"n"`
+			Role     int    `json:"p"`
+		} `json:"u"`
+	}{}
+	if err := json.Unmarshal(msg, &raw); err != nil {
+		plug.Log.WithField("action", "modStaff").Warnln("could not unmarshal moderation action", err)
+		return
+	}
+
+	for _, u := range raw.Users {
+		// keep our idea of their role up to date
+		plug.Room.Lock()
+		for i := range plug.Room.users {
+			if plug.Room.users[i].ID == u.ID {
+				plug.Room.users[i].Role = u.Role
+			}
+		}
+		plug.Room.Unlock()
+
+		if u.ID == plug.Me().ID {
+			role := u.Role
+			plug.updateMe(func(me *Profile) { me.Role = role })
+		}
+
+		handleModeration(plug, AuditStaff, ModerateStaffEvent, modAction{
+			Moderator:   raw.Moderator,
+			ModeratorID: raw.ModeratorID,
+		}, AuditEntry{
+			Target: AuditUser{u.ID, u.Username},
+			Detail: "role " + strconv.Itoa(u.Role),
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package plugapi
+
+import (
+	"encoding/json"
+	log "github.com/Sirupsen/logrus"
+	"testing"
+	"time"
+)
+
+// listenFor registers a handler sending every payload of the events on the channel
+func listenFor(plug *PlugDJ, events ...Event) chan interface{} {
+	ch := make(chan interface{}, 16)
+	plug.RegisterEvents(func(_ *PlugDJ, payload interface{}) { ch <- payload }, events...)
+	return ch
+}
+
+// nextPayload waits for the next payload on the channel
+func nextPayload(t *testing.T, ch chan interface{}) interface{} {
+	t.Helper()
+	select {
+	case payload := <-ch:
+		return payload
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return nil
+}
+
+// noPayload checks nothing else arrives on the channel
+func noPayload(t *testing.T, ch chan interface{}) {
+	t.Helper()
+	select {
+	case payload := <-ch:
+		t.Errorf("unexpected event with %+v", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEarnLevelUp(t *testing.T) {
+	plug := &PlugDJ{config: &Config{}, Log: log.New(), eventFuncs: make(map[Event]ProcessPayloadFunc), Room: &Room{}}
+	earned := listenFor(plug, EarnEvent)
+	levelled := listenFor(plug, LevelUpEvent)
+
+	// without knowing our level we can't tell if we levelled up
+	plug.setMe(Profile{User: User{ID: 1}})
+	handleAction_earn(plug, json.RawMessage(`{"xp":10,"pp":20,"level":2}`))
+	nextPayload(t, earned)
+	noPayload(t, levelled)
+
+	handleAction_earn(plug, json.RawMessage(`{"xp":30,"pp":25,"level":3}`))
+	if payload := nextPayload(t, earned).(EarnPayload); payload.XP != 30 {
+		t.Errorf("earn = %+v, want 30 XP", payload)
+	}
+	if payload := nextPayload(t, levelled).(LevelUpPayload); payload != (LevelUpPayload{2, 3}) {
+		t.Errorf("level up = %+v, want from 2 to 3", payload)
+	}
+
+	if me := plug.Me(); me.XP != 30 || me.PP != 25 || me.Level != 3 {
+		t.Errorf("me = %+v, want the earned XP, PP and level", me)
+	}
+}
+
+func TestGifted(t *testing.T) {
+	plug := &PlugDJ{config: &Config{}, Log: log.New(), eventFuncs: make(map[Event]ProcessPayloadFunc), Room: &Room{}}
+	plug.setMe(Profile{User: User{ID: 1, Username: "bot"}})
+	plug.Room.SetUsers([]User{{ID: 2, Username: "alice"}})
+	gifted := listenFor(plug, GiftedEvent)
+
+	handleAction_gifted(plug, json.RawMessage(`{"s":"alice","r":"Bot"}`))
+	payload := nextPayload(t, gifted).(GiftedPayload)
+	if payload.Sender.ID != 2 || payload.Recipient.ID != 1 {
+		t.Errorf("gift from %+v to %+v, want from alice to us", payload.Sender, payload.Recipient)
+	}
+}
@@ -0,0 +1,174 @@
+package plugapi
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ModerateDeleteMessage deletes a chat message
+func (plug *PlugDJ) ModerateDeleteMessage(messageID string) error {
+	return plug.moderateDelete(messageID, messageSender(messageID), "")
+}
+
+// moderateDelete deletes a chat message sent by target, auditing why
+func (plug *PlugDJ) moderateDelete(messageID string, target int, reason string) error {
+	if err := plug.deleteMessage(messageID); err != nil {
+		return err
+	}
+
+	plug.auditSelf(AuditDeleteChat, target, AuditEntry{Detail: messageID, Reason: reason})
+	return nil
+}
+
+// messageSender returns who sent the chat message, as message
+// IDs start with the ID of their sender (e.g. "1234-1500000000000")
+func messageSender(messageID string) int {
+	i := strings.Index(messageID, "-")
+	if i < 0 {
+		return 0
+	}
+
+	id, err := strconv.Atoi(messageID[:i])
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// deleteMessage deletes a chat message without auditing it,
+// for tidying up our own messages which isn't moderation
+func (plug *PlugDJ) deleteMessage(messageID string) error {
+	if err := plug.DeleteData(ChatDeleteEndpoint+messageID, nil, nil); err != nil {
+		plug.Log.WithField("error", err).Warnln("plugapi: could not delete chat message")
+		return err
+	}
+
+	return nil
+}
+
+// ModerateSkip skips the current play. The DJ and history ID
+// must be those of the current play, so that we never skip the
+// play after it if the current one ended in the meantime.
+func (plug *PlugDJ) ModerateSkip(userID int, historyID string) error {
+	return plug.moderateSkip(userID, historyID, "")
+}
+
+// moderateSkip is ModerateSkip, auditing why the play was skipped
+func (plug *PlugDJ) moderateSkip(userID int, historyID, reason string) error {
+	err := plug.PostData(ModerateSkipEndpoint, map[string]interface{}{
+		"userID":    userID,
+		"historyID": historyID,
+	}, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	plug.Room.skip(historyID)
+	plug.auditSelf(AuditSkip, userID, AuditEntry{Detail: historyID, Reason: reason})
+	return nil
+}
+
+// ModerateAddDJ adds the user to the back of the waitlist
+func (plug *PlugDJ) ModerateAddDJ(userID int) error {
+	return plug.moderateAddDJ(userID, "")
+}
+
+// moderateAddDJ is ModerateAddDJ, auditing why they were added
+func (plug *PlugDJ) moderateAddDJ(userID int, reason string) error {
+	if err := plug.PostData(ModerateAddDJEndpoint, map[string]int{"id": userID}, nil, nil); err != nil {
+		return err
+	}
+
+	plug.auditSelf(AuditAddDJ, userID, AuditEntry{Reason: reason})
+	return nil
+}
+
+// ModerateRemoveDJ removes the user from the waitlist (or the booth)
+func (plug *PlugDJ) ModerateRemoveDJ(userID int) error {
+	return plug.moderateRemoveDJ(userID, "")
+}
+
+// moderateRemoveDJ is ModerateRemoveDJ, auditing why they were removed
+func (plug *PlugDJ) moderateRemoveDJ(userID int, reason string) error {
+	if err := plug.DeleteData(ModerateRemoveDJEndpoint+strconv.Itoa(userID), nil, nil); err != nil {
+		return err
+	}
+
+	plug.auditSelf(AuditRemoveDJ, userID, AuditEntry{Reason: reason})
+	return nil
+}
+
+// ModerateMoveDJ moves the user in the waitlist, positions start at zero
+func (plug *PlugDJ) ModerateMoveDJ(userID, position int) error {
+	return plug.moderateMoveDJ(userID, position, "")
+}
+
+// moderateMoveDJ is ModerateMoveDJ, auditing why they were moved
+func (plug *PlugDJ) moderateMoveDJ(userID, position int, reason string) error {
+	err := plug.PostData(ModerateMoveDJEndpoint, map[string]int{
+		"userID":   userID,
+		"position": position,
+	}, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	plug.auditSelf(AuditMoveDJ, userID, AuditEntry{Detail: strconv.Itoa(position), Reason: reason})
+	return nil
+}
+
+// MuteDuration is how long ModerateMute mutes someone for
+type MuteDuration string
+
+const (
+	MuteShort  MuteDuration = "s" // 15 minutes
+	MuteMedium MuteDuration = "m" // 30 minutes
+	MuteLong   MuteDuration = "l" // 45 minutes
+)
+
+// BanDuration is how long ModerateBan bans someone for
+type BanDuration string
+
+const (
+	BanHour    BanDuration = "h"
+	BanDay     BanDuration = "d"
+	BanForever BanDuration = "f"
+)
+
+// ModerateMute stops the user from chatting. The reason is
+// one of plug's reason codes, from 1 to 5 as listed on plug.
+func (plug *PlugDJ) ModerateMute(userID int, duration MuteDuration, reason int) error {
+	err := plug.PostData(ModerateMuteEndpoint, map[string]interface{}{
+		"userID":   userID,
+		"duration": duration,
+		"reason":   reason,
+	}, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	plug.auditSelf(AuditMute, userID, AuditEntry{
+		Duration: muteLengths[duration],
+		Reason:   reasonText(muteReasons, reason),
+	})
+	return nil
+}
+
+// ModerateBan bans the user from the room. The reason is
+// one of plug's reason codes, from 1 to 5 as listed on plug.
+func (plug *PlugDJ) ModerateBan(userID int, duration BanDuration, reason int) error {
+	err := plug.PostData(ModerateBanEndpoint, map[string]interface{}{
+		"userID":   userID,
+		"duration": duration,
+		"reason":   reason,
+	}, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	plug.auditSelf(AuditBan, userID, AuditEntry{
+		Duration: banLengths[duration],
+		Reason:   reasonText(banReasons, reason),
+	})
+	return nil
+}
@@ -1,5 +1,7 @@
 package plugapi
 
+import "time"
+
 // This file is dedicated towards structs that definitely need to be
 // accessed by other packages.
 
@@ -7,10 +9,27 @@ package plugapi
 // unless it can be directly unmarshalled (with the exception of IntBool)
 
 type ChatPayload struct {
-	Message   string // The chat message
+	Message   string // The chat message, unescaped and without any "/me "
 	MessageID string
 	User      *User // Who it came from
 	Type      chatMessageType
+
+	Mentions   []*User       // Everyone mentioned, including through specials
+	Specials   []mentionType // Special mentions used (@everyone, @djs, @staff)
+	Emotes     []string      // Names of :emoji: tokens, without the colons
+	Links      []string      // URLs found in the message
+	MentionsMe bool          // Were we mentioned?
+}
+
+// ChatModerationPayload is sent when a chat message breaks
+// one of the rules of Config.ChatModeration
+type ChatModerationPayload struct {
+	Chat    ChatPayload
+	Rule    string // name of the rule broken
+	Reason  string // why it was broken
+	Strikes int    // how many strikes the user has now
+	Action  ChatAction
+	Err     error // why the action couldn't be taken, if it couldn't
 }
 
 type AdvancePayload struct {
@@ -24,5 +43,86 @@ type AdvancePayload struct {
 	Playback *Playback
 }
 
+// MediaPolicyPayload is sent when a play breaks one of Config.MediaPolicies
+type MediaPolicyPayload struct {
+	Playback *Playback
+	DJ       *User
+	Policy   string // name of the policy broken
+	Reason   string // why it was broken
+	Action   PolicyAction
+	Err      error // why the action couldn't be taken, if it couldn't
+}
+
+// ModerationPayload is sent for the Moderate*Event events and ChatDeleteEvent
+type ModerationPayload struct{ AuditEntry }
+
+// VotePayload is sent when someone woots or mehs the current play
+type VotePayload struct {
+	User *User
+	Vote int // 1 for a woot, -1 for a meh
+}
+
+// GrabPayload is sent when someone grabs the current play
+type GrabPayload struct {
+	User *User
+}
+
+// UserIdlePayload is sent when someone in the waitlist has been idle for
+// Config.IdleTimeout. It is only sent again once they have done something.
+type UserIdlePayload struct {
+	User     User
+	IdleFor  time.Duration // since they last joined, chatted or voted
+	Position int           // where they are in the waitlist, starting at zero
+}
+
+// DJListUpdatePayload is sent when the waitlist changes
+type DJListUpdatePayload struct {
+	DJs []User // the waitlist, in order
+}
+
 type UserJoinPayload struct{ User }
 type UserLeavePayload struct{ User }
+
+// FollowJoinPayload is sent when a friend joins the room
+type FollowJoinPayload struct{ User }
+
+type FriendRequestPayload struct {
+	User *User // Who wants to be our friend
+}
+
+type EarnPayload struct {
+	XP    int `json:"xp"`    // our experience points now
+	PP    int `json:"pp"`    // our plug points now
+	Level int `json:"level"` // our level now
+}
+
+type LevelUpPayload struct {
+	OldLevel int
+	Level    int
+}
+
+type GiftedPayload struct {
+	Sender    *User // Who sent the gift
+	Recipient *User // Who received it
+}
+
+type MaintModeAlertPayload struct {
+	Minutes int // How long until plug goes down for maintenance
+}
+
+type HeartbeatMissedPayload struct {
+	LastHeartbeat time.Time // When plug last sent us an "h"
+	LastFrame     time.Time // When plug last sent us anything
+}
+
+type TrackEndingPayload struct {
+	Playback  *Playback
+	DJ        *User
+	Remaining time.Duration // How much of the play is left
+}
+
+type StuckTrackPayload struct {
+	Playback *Playback
+	DJ       *User
+	Overdue  time.Duration // How long ago the play should have ended
+}
@@ -0,0 +1,125 @@
+package plugapi
+
+import (
+	"time"
+)
+
+// How long past its duration a play may go before it is stuck,
+// unless Config.StuckTrackGrace says otherwise
+const defaultStuckTrackGrace = 10 * time.Second
+
+// Length returns how long the media is
+func (m Media) Length() time.Duration {
+	if m.Duration <= 0 {
+		return 0
+	}
+	return time.Duration(m.Duration) * time.Second
+}
+
+// ElapsedAt returns how far into the play we are at the given
+// (server) time. It never returns more than the media's length.
+func (p *Playback) ElapsedAt(now time.Time) time.Duration {
+	if p == nil || p.StartTime.IsZero() {
+		return 0
+	}
+
+	elapsed := now.Sub(p.StartTime)
+	if elapsed < 0 {
+		return 0
+	}
+
+	if length := p.Media.Length(); length > 0 && elapsed > length {
+		return length
+	}
+	return elapsed
+}
+
+// RemainingAt returns how much of the play is left at the given (server) time
+func (p *Playback) RemainingAt(now time.Time) time.Duration {
+	if p == nil {
+		return 0
+	}
+
+	return p.Media.Length() - p.ElapsedAt(now)
+}
+
+// Elapsed returns how far into the current play we are
+func (plug *PlugDJ) Elapsed() time.Duration {
+	plug.Room.RLock()
+	defer plug.Room.RUnlock()
+
+	return plug.Room.Playback.ElapsedAt(plug.ServerNow())
+}
+
+// Remaining returns how much of the current play is left
+func (plug *PlugDJ) Remaining() time.Duration {
+	plug.Room.RLock()
+	defer plug.Room.RUnlock()
+
+	return plug.Room.Playback.RemainingAt(plug.ServerNow())
+}
+
+// scheduleTrackTimers replaces the timers for the
+// TrackEndingEvent and StuckTrackEvent of the last play
+func (plug *PlugDJ) scheduleTrackTimers(playback *Playback) {
+	plug.trackLock.Lock()
+	defer plug.trackLock.Unlock()
+
+	for _, timer := range plug.trackTimers {
+		timer.Stop()
+	}
+	plug.trackTimers = nil
+
+	if playback == nil || playback.Media.Length() == 0 {
+		return
+	}
+
+	remaining := playback.RemainingAt(plug.ServerNow())
+	historyID := playback.HistoryID
+
+	if notice := plug.config.TrackEndingNotice; notice > 0 && remaining > notice {
+		plug.trackTimers = append(plug.trackTimers, time.AfterFunc(remaining-notice, func() {
+			if current, dj := plug.currentPlay(historyID); current != nil {
+				plug.emitEvent(TrackEndingEvent, TrackEndingPayload{
+					Playback:  current,
+					DJ:        dj,
+					Remaining: current.RemainingAt(plug.ServerNow()),
+				})
+			}
+		}))
+	}
+
+	grace := plug.config.StuckTrackGrace
+	if grace < 0 {
+		return
+	} else if grace == 0 {
+		grace = defaultStuckTrackGrace
+	}
+
+	plug.trackTimers = append(plug.trackTimers, time.AfterFunc(remaining+grace, func() {
+		if current, dj := plug.currentPlay(historyID); current != nil {
+			overdue := plug.ServerNow().Sub(current.StartTime) - current.Media.Length()
+			plug.Log.WithField("historyID", historyID).Warnln("play has not advanced, track is stuck")
+			plug.emitEvent(StuckTrackEvent, StuckTrackPayload{
+				Playback: current,
+				DJ:       dj,
+				Overdue:  overdue,
+			})
+		}
+	}))
+}
+
+// currentPlay returns a copy of the playback and its DJ if
+// it's still the one with this history ID, nil otherwise
+func (plug *PlugDJ) currentPlay(historyID string) (*Playback, *User) {
+	plug.Room.RLock()
+	playback := plug.Room.Playback
+	plug.Room.RUnlock()
+
+	if playback == nil || playback.HistoryID != historyID {
+		return nil, nil
+	}
+
+	current := *playback
+	return &current, plug.Room.getDJ()
+}
@@ -0,0 +1,142 @@
+package plugapi
+
+import (
+	"encoding/json"
+	log "github.com/Sirupsen/logrus"
+	"testing"
+	"time"
+)
+
+func TestPlaybackProgress(t *testing.T) {
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	playback := &Playback{Media: Media{Duration: 180}, StartTime: start}
+
+	tests := []struct {
+		at        time.Duration
+		elapsed   time.Duration
+		remaining time.Duration
+	}{
+		{-time.Minute, 0, 3 * time.Minute},
+		{0, 0, 3 * time.Minute},
+		{time.Minute, time.Minute, 2 * time.Minute},
+		{4 * time.Minute, 3 * time.Minute, 0},
+	}
+
+	for _, test := range tests {
+		now := start.Add(test.at)
+		if got := playback.ElapsedAt(now); got != test.elapsed {
+			t.Errorf("ElapsedAt(+%s) = %s, want %s", test.at, got, test.elapsed)
+		}
+		if got := playback.RemainingAt(now); got != test.remaining {
+			t.Errorf("RemainingAt(+%s) = %s, want %s", test.at, got, test.remaining)
+		}
+	}
+
+	// nothing playing is never in progress
+	var none *Playback
+	if none.ElapsedAt(start) != 0 || none.RemainingAt(start) != 0 {
+		t.Error("a nil playback has progress")
+	}
+}
+
+// newPlaybackPlug returns a session in a room with alice DJing
+func newPlaybackPlug(config Config) *PlugDJ {
+	config.Log = log.New()
+	plug := &PlugDJ{
+		config:     &config,
+		Log:        config.Log,
+		Room:       &Room{},
+		clock:      &ServerClock{},
+		eventFuncs: make(map[Event]ProcessPayloadFunc),
+		activity:   make(map[int]*Activity),
+	}
+	plug.Room.SetUsers([]User{{ID: 2, Username: "alice"}, {ID: 3, Username: "bob"}})
+	return plug
+}
+
+func TestAdvance(t *testing.T) {
+	plug := newPlaybackPlug(Config{StuckTrackGrace: -1})
+	advances := listenFor(plug, AdvanceEvent)
+
+	handleAction_advance(plug, json.RawMessage(`{"c":2,"d":[3],"h":"one","m":{"cid":"a","duration":200},"p":1,"t":"2018-01-01 12:00:00.000000"}`))
+	payload := nextPayload(t, advances).(AdvancePayload)
+	if payload.CurrentDJ == nil || payload.CurrentDJ.ID != 2 || len(payload.DJs) != 1 || payload.LastPlay != nil {
+		t.Errorf("advance = %+v, want alice playing with bob waiting", payload)
+	}
+	if want := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC); !payload.Playback.StartTime.Equal(want) {
+		t.Errorf("start time = %s, want %s", payload.Playback.StartTime, want)
+	}
+
+	// bob changes his mind, which only counts once
+	handleAction_vote(plug, json.RawMessage(`{"i":3,"v":-1}`))
+	handleAction_vote(plug, json.RawMessage(`{"i":3,"v":1}`))
+	handleAction_grab(plug, json.RawMessage(`3`))
+
+	handleAction_advance(plug, json.RawMessage(`{"c":3,"d":[],"h":"two","m":{"cid":"b","duration":100},"p":1,"t":"2018-01-01 12:03:20.000000"}`))
+	payload = nextPayload(t, advances).(AdvancePayload)
+	if payload.LastPlay == nil || payload.LastPlay.DJ.ID != 2 || payload.LastPlay.Media.CID != "a" {
+		t.Errorf("last play = %+v, want alice's", payload.LastPlay)
+	}
+	if want := (PlayScore{Grabs: 1, Listeners: 2, Positive: 1}); payload.LastPlay == nil || payload.LastPlay.Score != want {
+		t.Errorf("last play = %+v, want a score of %+v", payload.LastPlay, want)
+	}
+	if score := plug.Room.score(); score != (PlayScore{Listeners: 2}) {
+		t.Errorf("score = %+v, want a fresh score for the new play", score)
+	}
+
+	// a skip that came too late for the play isn't put on the next one
+	plug.Room.skip("one")
+	if score := plug.Room.score(); score.Skipped != 0 {
+		t.Errorf("score = %+v, want the new play not skipped", score)
+	}
+	plug.Room.skip("two")
+	if score := plug.Room.score(); score.Skipped != 1 {
+		t.Errorf("score = %+v, want the new play skipped", score)
+	}
+	if payload.CurrentDJ == nil || payload.CurrentDJ.ID != 3 {
+		t.Errorf("current DJ = %+v, want bob", payload.CurrentDJ)
+	}
+
+	// an empty booth plays nothing
+	handleAction_advance(plug, json.RawMessage(`{"c":-1,"d":[]}`))
+	if payload = nextPayload(t, advances).(AdvancePayload); payload.Playback != nil || plug.Room.Playback != nil {
+		t.Errorf("playback = %+v, want nothing playing", payload.Playback)
+	}
+}
+
+func TestTrackTimers(t *testing.T) {
+	plug := newPlaybackPlug(Config{TrackEndingNotice: 30 * time.Millisecond, StuckTrackGrace: 20 * time.Millisecond})
+	ending := listenFor(plug, TrackEndingEvent)
+	stuck := listenFor(plug, StuckTrackEvent)
+
+	// a play with 50ms left
+	playback := &Playback{HistoryID: "one", Media: Media{Duration: 1}, StartTime: plug.ServerNow().Add(-950 * time.Millisecond)}
+	plug.Room.Booth.CurrentDJ = 2
+	plug.Room.Playback = playback
+	plug.scheduleTrackTimers(playback)
+
+	if payload := nextPayload(t, ending).(TrackEndingPayload); payload.DJ.ID != 2 || payload.Remaining > 30*time.Millisecond {
+		t.Errorf("track ending = %+v, want alice's play with under 30ms left", payload)
+	}
+	if payload := nextPayload(t, stuck).(StuckTrackPayload); payload.Playback.HistoryID != "one" || payload.Overdue < 20*time.Millisecond {
+		t.Errorf("stuck track = %+v, want the play at least 20ms overdue", payload)
+	}
+}
+
+func TestTrackTimersStopOnAdvance(t *testing.T) {
+	plug := newPlaybackPlug(Config{TrackEndingNotice: 30 * time.Millisecond, StuckTrackGrace: 20 * time.Millisecond})
+	ending := listenFor(plug, TrackEndingEvent)
+	stuck := listenFor(plug, StuckTrackEvent)
+
+	playback := &Playback{HistoryID: "one", Media: Media{Duration: 1}, StartTime: plug.ServerNow().Add(-950 * time.Millisecond)}
+	plug.Room.Playback = playback
+	plug.scheduleTrackTimers(playback)
+
+	// the next play replaces the timers of the last one
+	plug.Room.Playback = nil
+	plug.scheduleTrackTimers(nil)
+
+	time.Sleep(100 * time.Millisecond)
+	noPayload(t, ending)
+	noPayload(t, stuck)
+}
@@ -0,0 +1,173 @@
+package plugapi
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MediaRule checks a play, returning why it breaks the rule or
+// "" if it doesn't. Reasons read like "it is longer than 10m0s".
+// The DJ may only have an ID if we don't know who they are.
+type MediaRule func(plug *PlugDJ, playback *Playback, dj *User) string
+
+// PolicyAction says what is done about a play that breaks a
+// MediaPolicy. Actions can be combined, e.g. WarnAction|SkipAction.
+type PolicyAction int
+
+const (
+	WarnAction     PolicyAction = 1 << iota // tell the DJ why in chat
+	SkipAction                              // skip the play
+	RemoveAction                            // skip the play and take the DJ out of the waitlist
+	MoveBackAction                          // skip the play and put the DJ at the back of the waitlist
+)
+
+// MediaPolicy is checked on every advance, see Config.MediaPolicies
+type MediaPolicy struct {
+	Name   string // used in logs and the MediaPolicyEvent
+	Rule   MediaRule
+	Action PolicyAction
+}
+
+// MaxDuration breaks plays of media longer than max
+func MaxDuration(max time.Duration) MediaRule {
+	return func(_ *PlugDJ, playback *Playback, _ *User) string {
+		if playback.Media.Length() > max {
+			return "it is longer than " + max.String()
+		}
+		return ""
+	}
+}
+
+// BlacklistCIDs breaks plays of the media with these cids
+func BlacklistCIDs(cids ...string) MediaRule {
+	blacklist := make(map[string]bool, len(cids))
+	for _, cid := range cids {
+		blacklist[cid] = true
+	}
+
+	return func(_ *PlugDJ, playback *Playback, _ *User) string {
+		if blacklist[playback.Media.CID] {
+			return "it is blacklisted"
+		}
+		return ""
+	}
+}
+
+// BlacklistPattern breaks plays of media whose "author - title" matches
+func BlacklistPattern(pattern *regexp.Regexp) MediaRule {
+	return func(_ *PlugDJ, playback *Playback, _ *User) string {
+		if pattern.MatchString(playback.Media.Author + " - " + playback.Media.Title) {
+			return "it is blacklisted"
+		}
+		return ""
+	}
+}
+
+// RecentlyPlayed breaks plays of media that was played in the last
+// plays plays or the last within duration, just like PlayedRecently
+func RecentlyPlayed(plays int, within time.Duration) MediaRule {
+	return func(plug *PlugDJ, playback *Playback, _ *User) string {
+		if item, ok := plug.PlayedRecently(playback.Media.CID, plays, within); ok {
+			ago := plug.ServerNow().Sub(item.Timestamp)
+			if ago < time.Minute {
+				return "it was just played"
+			}
+			return "it was played " + strings.TrimSuffix(ago.Round(time.Minute).String(), "0s") + " ago"
+		}
+		return ""
+	}
+}
+
+// Unavailable breaks plays of media that can't be played. Without a
+// check only media that plug has no cid or duration for is broken,
+// the check can ask YouTube or SoundCloud if the media still exists.
+func Unavailable(check func(media Media) bool) MediaRule {
+	return func(_ *PlugDJ, playback *Playback, _ *User) string {
+		media := playback.Media
+		if media.CID == "" || media.Duration <= 0 || (check != nil && !check(media)) {
+			return "it is unavailable"
+		}
+		return ""
+	}
+}
+
+// enforceMediaPolicies checks the play against Config.MediaPolicies.
+// Only the first policy that is broken is acted upon.
+func (plug *PlugDJ) enforceMediaPolicies(playback *Playback, dj *User) {
+	for _, policy := range plug.config.MediaPolicies {
+		if policy.Rule == nil {
+			continue
+		}
+
+		reason := policy.Rule(plug, playback, dj)
+		if reason == "" {
+			continue
+		}
+
+		plug.Log.WithFields(log.Fields{
+			"policy":    policy.Name,
+			"reason":    reason,
+			"cid":       playback.Media.CID,
+			"dj":        dj.ID,
+			"historyID": playback.HistoryID,
+		}).Infoln("play broke media policy")
+
+		err := plug.applyPolicy(policy.Action, playback, dj, reason)
+		if err != nil {
+			plug.Log.WithField("error", err).Warnln("could not enforce media policy")
+		}
+
+		plug.emitEvent(MediaPolicyEvent, MediaPolicyPayload{
+			Playback: playback,
+			DJ:       dj,
+			Policy:   policy.Name,
+			Reason:   reason,
+			Action:   policy.Action,
+			Err:      err,
+		})
+		return
+	}
+}
+
+// applyPolicy does what the action says, returning the first error.
+// A warning we couldn't send doesn't stop the play from being skipped.
+func (plug *PlugDJ) applyPolicy(action PolicyAction, playback *Playback, dj *User, reason string) error {
+	skip := action&(SkipAction|RemoveAction|MoveBackAction) != 0
+
+	var errs []error
+	if action&WarnAction != 0 && dj.Username != "" {
+		text := "your play breaks the rules, " + reason
+		if skip {
+			text = "your play was skipped, " + reason
+		}
+
+		errs = append(errs, plug.Mention(dj, text))
+	}
+
+	if skip {
+		errs = append(errs, plug.moderateSkip(dj.ID, playback.HistoryID, reason))
+	}
+
+	switch {
+	case action&RemoveAction != 0:
+		errs = append(errs, plug.moderateRemoveDJ(dj.ID, reason))
+	case action&MoveBackAction != 0:
+		// skipped DJs are only put back in the waitlist if the booth cycles
+		plug.Room.RLock()
+		cycles := plug.Room.Booth.ShouldCycle
+		plug.Room.RUnlock()
+
+		if !cycles {
+			errs = append(errs, plug.moderateAddDJ(dj.ID, reason))
+		}
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package plugapi
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMediaRules(t *testing.T) {
+	plug := newHistoryPlug(10)
+	plug.clock.set(time.Date(2018, 1, 1, 12, 30, 0, 0, time.UTC))
+
+	short := &Playback{Media: Media{CID: "cid9", Author: "Band", Title: "Song", Duration: 200}}
+	long := &Playback{Media: Media{CID: "new", Author: "Band", Title: "Long Song (10 hours)", Duration: 36000}}
+	gone := &Playback{Media: Media{CID: "gone", Duration: -1}}
+
+	tests := []struct {
+		name     string
+		rule     MediaRule
+		playback *Playback
+		want     string
+	}{
+		{"short enough", MaxDuration(10 * time.Minute), short, ""},
+		{"too long", MaxDuration(10 * time.Minute), long, "it is longer than 10m0s"},
+		{"not blacklisted", BlacklistCIDs("a", "b"), short, ""},
+		{"blacklisted cid", BlacklistCIDs("a", "cid9"), short, "it is blacklisted"},
+		{"pattern", BlacklistPattern(regexp.MustCompile(`\(10 hours\)`)), long, "it is blacklisted"},
+		{"pattern misses", BlacklistPattern(regexp.MustCompile(`\(10 hours\)`)), short, ""},
+		{"played recently", RecentlyPlayed(0, time.Hour), &Playback{Media: Media{CID: "cid0"}}, "it was played 21m ago"},
+		{"not played recently", RecentlyPlayed(0, time.Hour), long, ""},
+		{"available", Unavailable(nil), short, ""},
+		{"unavailable", Unavailable(nil), gone, "it is unavailable"},
+		{"checked", Unavailable(func(Media) bool { return false }), short, "it is unavailable"},
+	}
+
+	for _, test := range tests {
+		if got := test.rule(plug, test.playback, &User{ID: 2}); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEnforceMediaPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		action PolicyAction
+		cycles bool
+		want   []string
+	}{
+		{"skip", SkipAction, false, []string{"POST " + ModerateSkipEndpoint}},
+		{"remove", RemoveAction, false, []string{"POST " + ModerateSkipEndpoint, "DELETE " + ModerateRemoveDJEndpoint + "2"}},
+		{"move back", MoveBackAction, false, []string{"POST " + ModerateSkipEndpoint, "POST " + ModerateAddDJEndpoint}},
+		{"move back when cycling", MoveBackAction, true, []string{"POST " + ModerateSkipEndpoint}},
+	}
+
+	for _, test := range tests {
+		var lock sync.Mutex
+		var requests []string
+		plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			requests = append(requests, r.Method+" "+r.URL.Path[len("/_"):])
+			lock.Unlock()
+			writeEnvelope(w, "ok", []struct{}{})
+		})
+		plug.eventFuncs = make(map[Event]ProcessPayloadFunc)
+		plug.Room = &Room{}
+		plug.Room.Booth.ShouldCycle = test.cycles
+		plug.config.MediaPolicies = []MediaPolicy{
+			{Name: "fine", Rule: MaxDuration(time.Hour), Action: SkipAction},
+			{Name: "too long", Rule: MaxDuration(time.Minute), Action: test.action},
+			{Name: "also too long", Rule: MaxDuration(time.Second), Action: RemoveAction},
+		}
+		broken := listenFor(plug, MediaPolicyEvent)
+
+		playback := &Playback{HistoryID: "h", Media: Media{CID: "a", Duration: 200}}
+		plug.enforceMediaPolicies(playback, &User{ID: 2})
+
+		payload := nextPayload(t, broken).(MediaPolicyPayload)
+		if payload.Policy != "too long" || payload.Action != test.action || payload.Err != nil {
+			t.Errorf("%s: event = %+v, want the first broken policy", test.name, payload)
+		}
+		noPayload(t, broken)
+
+		lock.Lock()
+		if strings.Join(requests, ", ") != strings.Join(test.want, ", ") {
+			t.Errorf("%s: requests = %q, want %q", test.name, requests, test.want)
+		}
+		lock.Unlock()
+	}
+}
+
+func TestEnforceMediaPoliciesFails(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		writeEnvelope(w, "notAuthorized", []struct{}{})
+	})
+	plug.eventFuncs = make(map[Event]ProcessPayloadFunc)
+	plug.Room = &Room{}
+	plug.config.MediaPolicies = []MediaPolicy{{Name: "unavailable", Rule: Unavailable(nil), Action: SkipAction}}
+	broken := listenFor(plug, MediaPolicyEvent)
+
+	plug.enforceMediaPolicies(&Playback{HistoryID: "h"}, &User{ID: 2})
+	if payload := nextPayload(t, broken).(MediaPolicyPayload); payload.Err == nil {
+		t.Errorf("event = %+v, want the error from skipping", payload)
+	}
+}
+
+func TestEnforceMediaPoliciesWarningFails(t *testing.T) {
+	var lock sync.Mutex
+	var requests []string
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path[len("/_"):])
+		lock.Unlock()
+		writeEnvelope(w, "ok", []struct{}{})
+	})
+	plug.eventFuncs = make(map[Event]ProcessPayloadFunc)
+	plug.Room = &Room{}
+	plug.config.MediaPolicies = []MediaPolicy{{Name: "unavailable", Rule: Unavailable(nil), Action: WarnAction | SkipAction}}
+	broken := listenFor(plug, MediaPolicyEvent)
+
+	// we have no socket, so the warning can't be sent
+	plug.enforceMediaPolicies(&Playback{HistoryID: "h"}, &User{ID: 2, Username: "alice"})
+	if payload := nextPayload(t, broken).(MediaPolicyPayload); payload.Err == nil {
+		t.Errorf("event = %+v, want the error from warning", payload)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if want := []string{"POST " + ModerateSkipEndpoint}; strings.Join(requests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("requests = %q, want the play skipped anyway", requests)
+	}
+}
@@ -0,0 +1,181 @@
+package plugapi
+
+import (
+	"errors"
+	"strings"
+)
+
+// Profile is everything plug tells us about the logged in user
+type Profile struct {
+	User
+
+	XP int `json:"xp"` // experience points towards the next level
+	PP int `json:"pp"` // plug points, spent in the store
+
+	// All of the user's settings. Most of these only matter
+	// to the website, see NotificationSettings for the rest.
+	Settings map[string]interface{} `json:"settings"`
+}
+
+// NotificationSettings are the settings deciding
+// what the logged in user gets notified about
+type NotificationSettings struct {
+	DJ           bool `json:"notifyDJ"`         // when it's nearly our turn
+	FriendJoin   bool `json:"notifyFriendJoin"` // when a friend joins the room
+	Score        bool `json:"notifyScore"`      // our score after each play
+	MentionSound bool `json:"mentionSound"`     // play a sound when mentioned
+}
+
+// Me returns a copy of the profile of the logged in user.
+// It is only as fresh as the last call to RefreshMe.
+func (plug *PlugDJ) Me() Profile {
+	plug.meLock.RLock()
+	defer plug.meLock.RUnlock()
+
+	if plug.me == nil {
+		return Profile{}
+	}
+
+	me := *plug.me
+	me.Settings = copySettings(plug.me.Settings)
+	return me
+}
+
+// RefreshMe fetches the profile of the logged in user
+// from plug, updating both Me() and plug.User
+func (plug *PlugDJ) RefreshMe() (Profile, error) {
+	var data []Profile
+	if err := plug.GetData(UserInfoEndpoint, &data, nil); err != nil {
+		return Profile{}, err
+	}
+
+	if len(data) == 0 {
+		return Profile{}, ErrUnknownData
+	}
+
+	plug.setMe(data[0])
+	return plug.Me(), nil
+}
+
+// setMe stores our profile. Our role depends on the room we are
+// in rather than on who we are, so it is kept from before.
+func (plug *PlugDJ) setMe(me Profile) {
+	plug.meLock.Lock()
+	defer plug.meLock.Unlock()
+
+	if plug.me != nil {
+		me.Role = plug.me.Role
+	}
+
+	plug.me = &me
+	plug.publishUser()
+}
+
+// updateMe changes our stored profile in place
+func (plug *PlugDJ) updateMe(fn func(me *Profile)) {
+	plug.meLock.Lock()
+	defer plug.meLock.Unlock()
+
+	if plug.me == nil {
+		return
+	}
+
+	fn(plug.me)
+	plug.publishUser()
+}
+
+// publishUser points plug.User at a new copy of our profile, so that
+// anyone still holding the old one never sees it change under them.
+// plug.meLock must already be locked.
+func (plug *PlugDJ) publishUser() {
+	user := plug.me.User
+	plug.User = &user
+}
+
+// SetBlurb changes the "about me" of the logged in user
+func (plug *PlugDJ) SetBlurb(blurb string) error {
+	if err := plug.PutData(UserBlurbEndpoint, map[string]string{"blurb": blurb}, nil, nil); err != nil {
+		return err
+	}
+
+	plug.updateMe(func(me *Profile) { me.Blurb = blurb })
+	return nil
+}
+
+// SetLanguage changes the language of the logged in user, e.g. "en"
+func (plug *PlugDJ) SetLanguage(language string) error {
+	if language == "" {
+		return errors.New("go-plugapi: language is empty")
+	}
+
+	if err := plug.PutData(UserLanguageEndpoint, map[string]string{"language": language}, nil, nil); err != nil {
+		return err
+	}
+
+	plug.updateMe(func(me *Profile) { me.Language = language })
+	return nil
+}
+
+// Notifications returns the notification settings of the logged in user
+func (plug *PlugDJ) Notifications() NotificationSettings {
+	settings := plug.Me().Settings
+
+	get := func(key string) bool {
+		b, _ := settings[key].(bool)
+		return b
+	}
+
+	return NotificationSettings{
+		DJ:           get("notifyDJ"),
+		FriendJoin:   get("notifyFriendJoin"),
+		Score:        get("notifyScore"),
+		MentionSound: get("mentionSound"),
+	}
+}
+
+// SetNotifications changes the notification settings of the logged in user.
+// plug wants all settings at once, so the other settings are sent unchanged.
+func (plug *PlugDJ) SetNotifications(n NotificationSettings) error {
+	settings := plug.Me().Settings
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+
+	settings["notifyDJ"] = n.DJ
+	settings["notifyFriendJoin"] = n.FriendJoin
+	settings["notifyScore"] = n.Score
+	settings["mentionSound"] = n.MentionSound
+
+	if err := plug.PutData(UserSettingsEndpoint, settings, nil, nil); err != nil {
+		return err
+	}
+
+	plug.updateMe(func(me *Profile) { me.Settings = settings })
+	return nil
+}
+
+// userByName finds a user by their username, looking at ourself
+// and then the room. Users we can't find only have a Username.
+func (plug *PlugDJ) userByName(name string) *User {
+	if me := plug.Me().User; strings.EqualFold(me.Username, name) {
+		return &me
+	}
+
+	if user := plug.Room.getUserByName(name); user != nil {
+		return user
+	}
+
+	return &User{Username: name}
+}
+
+func copySettings(settings map[string]interface{}) map[string]interface{} {
+	if settings == nil {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package plugapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestRefreshMeKeepsRole(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		writeEnvelope(w, "ok", []Profile{{User: User{ID: 1, Username: "bot", Level: 5}, PP: 100}})
+	})
+	plug.me = &Profile{User: User{ID: 1, Role: 3000}}
+	plug.User = &User{ID: 1, Role: 3000}
+	old := plug.User
+
+	me, err := plug.RefreshMe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if me.Level != 5 || me.PP != 100 {
+		t.Errorf("me = %+v, want level 5 with 100 PP", me)
+	}
+	if me.Role != 3000 || plug.User.Role != 3000 {
+		t.Errorf("role = %d, want our role in the room kept", me.Role)
+	}
+	if plug.User.Level != 5 || plug.User.Role != 3000 {
+		t.Errorf("plug.User = %+v, want it in sync with Me", plug.User)
+	}
+	if old.Level != 0 {
+		t.Error("plug.User was changed in place")
+	}
+}
+
+func TestSetProfile(t *testing.T) {
+	requests := map[string]map[string]interface{}{}
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			writeEnvelope(w, "ok", []Profile{{
+				User:     User{ID: 1, Username: "bot"},
+				Settings: map[string]interface{}{"chatTimestamps": 12.0, "notifyDJ": true},
+			}})
+			return
+		}
+
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests[r.URL.Path] = body
+		writeEnvelope(w, "ok", []interface{}{})
+	})
+
+	if _, err := plug.RefreshMe(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := plug.SetBlurb("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := plug.SetLanguage("nl"); err != nil {
+		t.Fatal(err)
+	}
+	if err := plug.SetLanguage(""); err == nil {
+		t.Error("empty language was accepted")
+	}
+
+	n := plug.Notifications()
+	if !n.DJ || n.Score {
+		t.Errorf("notifications = %+v, want only DJ", n)
+	}
+	n.Score = true
+	if err := plug.SetNotifications(n); err != nil {
+		t.Fatal(err)
+	}
+
+	if me := plug.Me(); me.Blurb != "hello" || me.Language != "nl" || plug.User.Language != "nl" {
+		t.Errorf("me = %+v, want the new blurb and language", me)
+	}
+	if !plug.Notifications().Score {
+		t.Error("notification settings weren't updated")
+	}
+
+	// the settings we didn't change are sent back unchanged
+	if settings := requests["/_"+UserSettingsEndpoint]; settings["chatTimestamps"] != 12.0 || settings["notifyScore"] != true {
+		t.Errorf("settings sent = %v, want the old ones with notifyScore", settings)
+	}
+	if blurb := requests["/_"+UserBlurbEndpoint]; blurb["blurb"] != "hello" {
+		t.Errorf("blurb sent = %v", blurb)
+	}
+}
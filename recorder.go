@@ -0,0 +1,208 @@
+package plugapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	log "github.com/Sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Directions of recorded frames
+const (
+	FrameIn    = "in"    // received from the socket
+	FrameOut   = "out"   // sent to the socket
+	FrameState = "state" // our profile and the room state, after joining
+)
+
+// Frame is a single line of a recording
+type Frame struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"dir"`
+	Data      string    `json:"data"` // the frame exactly as it was sent
+}
+
+// Recorder writes every socket frame to a JSONL file (one Frame per
+// line) so that it can be fed back through Replay later on
+type Recorder struct {
+	sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// recordedState is what is recorded after joining a room, so
+// that a replay starts with the same Room and profile as we did
+type recordedState struct {
+	Me   Profile   `json:"me"`
+	Room *roomJson `json:"room"`
+}
+
+// NewRecorder creates (or appends to) the file at path
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{w: f, closer: f}, nil
+}
+
+// NewRecorderWriter records to any writer, it isn't closed by Close
+func NewRecorderWriter(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// Record writes a single frame
+func (r *Recorder) Record(direction string, data []byte) error {
+	line, err := json.Marshal(Frame{time.Now(), direction, string(data)})
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	_, err = r.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file being recorded to
+func (r *Recorder) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// record records a frame if we have a recorder. Secrets
+// (like the code in our "auth" frame) never hit the disk.
+func (plug *PlugDJ) record(direction string, data []byte) {
+	if plug.config.Recorder == nil {
+		return
+	}
+
+	if err := plug.config.Recorder.Record(direction, redact(data)); err != nil {
+		plug.Log.WithField("error", err).Warnln("could not record frame")
+	}
+}
+
+// recordState records our profile and the room we just joined
+func (plug *PlugDJ) recordState(room *roomJson) {
+	if plug.config.Recorder == nil {
+		return
+	}
+
+	room.RLock()
+	data, err := json.Marshal(recordedState{plug.Me(), room})
+	room.RUnlock()
+
+	if err != nil {
+		plug.Log.WithField("error", err).Warnln("could not record room state")
+		return
+	}
+
+	plug.record(FrameState, data)
+}
+
+// NewReplay returns a PlugDJ that isn't connected to plug at all,
+// for feeding recordings through with Replay. All REST requests and
+// socket messages sent by event handlers fail instead of being sent.
+func NewReplay(config Config) *PlugDJ {
+	if config.Log == nil {
+		config.Log = log.New()
+	}
+
+	config.Transport = offlineTransport{}
+	config.Recorder = nil // we don't want to record our replay
+
+	plug := newPlug(config)
+	plug.replaying = true
+	plug.setMe(Profile{})
+	plug.setState(ConnectedState)
+	return plug
+}
+
+// Replay feeds a recording made by a Recorder through our action
+// handlers, in order and one at a time. Event handlers are run before
+// the next frame is handled, so they have all returned once Replay has.
+// Room state recorded after joining a room is restored as it is found.
+// If realtime is true, the time between frames is the same as when
+// they were recorded.
+func (plug *PlugDJ) Replay(r io.Reader, realtime bool) error {
+	var last time.Time
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // room states are big
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return err
+		}
+
+		if realtime && !last.IsZero() && frame.Time.After(last) {
+			time.Sleep(frame.Time.Sub(last))
+		}
+		last = frame.Time
+
+		switch frame.Direction {
+		case FrameState:
+			if err := plug.restoreState([]byte(frame.Data)); err != nil {
+				return err
+			}
+		case FrameIn:
+			data := []byte(frame.Data)
+			if isHeartbeat(data) {
+				continue
+			}
+
+			messages, err := plug.parseFrame(data)
+			if err != nil {
+				return err
+			}
+
+			for _, msg := range messages {
+				handleAction(plug, msg)
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// restoreState restores a state recorded by recordState
+func (plug *PlugDJ) restoreState(data []byte) error {
+	var state recordedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	if state.Room == nil || state.Room.Room == nil {
+		return errors.New("plugapi: recorded state has no room")
+	}
+
+	// setMe keeps our old role, but we want the recorded one
+	plug.setMe(state.Me)
+	plug.updateMe(func(me *Profile) { me.Role = state.Me.Role })
+
+	plug.Room.replace(state.Room.room(), state.Room.Users)
+	plug.resetActivity(state.Room.Users)
+	return nil
+}
+
+// offlineTransport refuses to make any requests
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("plugapi: replaying, not sending " + req.Method + " " + req.URL.Path)
+}
@@ -0,0 +1,98 @@
+package plugapi
+
+import (
+	"bytes"
+	log "github.com/Sirupsen/logrus"
+	"strings"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorderWriter(&buf)
+
+	// a real recording starts with the ack to our auth
+	frames := []struct {
+		direction string
+		data      string
+	}{
+		{FrameIn, `[{"a":"ack","p":"1","s":"dashboard"}]`},
+		{FrameState, `{"me":{"id":1,"username":"bot","role":3000},"room":{"meta":{"slug":"test"},"booth":{"currentDJ":0,"waitingDJs":[]},"users":[{"id":2,"username":"alice"}]}}`},
+		{FrameIn, `h`},
+		{FrameIn, `[{"a":"userJoin","p":{"id":3,"username":"bob"},"s":"test"}]`},
+		{FrameIn, `[{"a":"userLeave","p":2,"s":"test"}]`},
+	}
+	for _, f := range frames {
+		if err := rec.Record(f.direction, []byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plug := NewReplay(Config{Log: log.New()})
+	if err := plug.Replay(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if slug := plug.Room.Meta.Slug; slug != "test" {
+		t.Errorf("room slug = %q, want %q", slug, "test")
+	}
+	if me := plug.Me(); me.ID != 1 || me.Role != 3000 {
+		t.Errorf("me = %+v, want id 1 with role 3000", me.User)
+	}
+
+	users := plug.Room.GetUsers()
+	if len(users) != 1 || users[0].ID != 3 {
+		t.Errorf("users = %+v, want only bob", users)
+	}
+}
+
+func TestReplayRunsHandlersInOrder(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorderWriter(&buf)
+	for _, data := range []string{
+		`{"me":{"id":1,"username":"bot"},"room":{"meta":{"slug":"test"},"booth":{"waitingDJs":[]},"users":[]}}`,
+		`[{"a":"userJoin","p":{"id":2,"username":"alice"},"s":"test"}]`,
+		`[{"a":"userJoin","p":{"id":3,"username":"bob"},"s":"test"}]`,
+		`[{"a":"userLeave","p":2,"s":"test"}]`,
+	} {
+		direction := FrameIn
+		if data[0] == '{' {
+			direction = FrameState
+		}
+		if err := rec.Record(direction, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// no locking, handlers must be done with by the time Replay returns
+	var seen []string
+	plug := NewReplay(Config{Log: log.New()})
+	plug.RegisterEvents(func(_ *PlugDJ, payload interface{}) {
+		switch p := payload.(type) {
+		case UserJoinPayload:
+			seen = append(seen, "join "+p.Username)
+		case UserLeavePayload:
+			seen = append(seen, "leave "+p.Username)
+		}
+	}, UserJoinEvent, UserLeaveEvent)
+
+	if err := plug.Replay(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "join alice, join bob, leave alice"
+	if got := strings.Join(seen, ", "); got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestRecordRedactsAuth(t *testing.T) {
+	var buf bytes.Buffer
+	plug := newPlug(Config{Log: log.New(), Recorder: NewRecorderWriter(&buf)})
+
+	plug.record(FrameOut, []byte(`{"a":"auth","p":"secret-session-code","t":1}`))
+
+	if strings.Contains(buf.String(), "secret-session-code") {
+		t.Errorf("recorded auth frame was not redacted: %s", buf.String())
+	}
+}
@@ -6,11 +6,11 @@ import (
 	"encoding/json"
 	"github.com/pkg/errors"
 	// log "github.com/Sirupsen/logrus"
-	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
-	// "net/url"
 )
 
 func (plug *PlugDJ) authenticateUser() error {
@@ -24,7 +24,7 @@ func (plug *PlugDJ) authenticateUser() error {
 
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return ErrUnknownResponse{resp, "/"}
+		return newAPIError(resp, "/")
 	}
 
 	// non authenticated requests contain a little js snippet
@@ -54,17 +54,13 @@ func (plug *PlugDJ) authenticateUser() error {
 
 	// try to log in
 	resp, err = plug.Post(AuthLoginEndpoint, data)
-	if err != nil {
+	if e, ok := asAPIError(err); ok && e.StatusCode == http.StatusUnauthorized {
+		return ErrAuthentication
+	} else if err != nil {
 		return err
 	}
 
 	resp.Body.Close()
-	if resp.StatusCode == 401 {
-		return ErrAuthentication
-	} else if resp.StatusCode != 200 {
-		return ErrUnknownResponse{resp, "/"}
-	}
-
 	return nil
 }
 
@@ -125,24 +121,133 @@ FinishedSearching:
 	return nil, errors.New("plugapi: could not find all variables")
 }
 
-// Get makes a get request to the plug API
-func (plug *PlugDJ) Get(endpoint string) (*http.Response, error) {
-	resp, err := plug.web.Get(plug.getAPIURL() + endpoint)
+// Request makes a request to the plug API. The query and body are optional,
+// the body is sent as json so it can be anything json.Marshal accepts.
+// A status code other than 200 is returned as an error. Failed requests
+// are retried according to Config.Retry.
+func (plug *PlugDJ) Request(method, endpoint string, query url.Values, body interface{}) (*http.Response, error) {
+	payload, err := marshalBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *http.Response
+	err = plug.retry(method, endpoint, func() (err error) {
+		resp, err = plug.do(method, endpoint, query, payload)
+		return err
+	})
+	return resp, err
+}
+
+// RequestData makes a request to the plug API and decodes the
+// response into data and meta, either of which may be nil
+func (plug *PlugDJ) RequestData(method, endpoint string, query url.Values, body, data, meta interface{}) error {
+	payload, err := marshalBody(body)
+	if err != nil {
+		return err
+	}
+
+	// plug sometimes says no in the envelope of a 200,
+	// so those have to be retried just like the rest
+	return plug.retry(method, endpoint, func() error {
+		resp, err := plug.do(method, endpoint, query, payload)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		return handleResponse(resp, endpoint, data, meta)
+	})
+}
+
+// marshalBody turns the body into json. nil means
+// there is no body at all (e.g. for GET)
+func marshalBody(body interface{}) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	return json.Marshal(body)
+}
+
+// do makes a single request to the plug API
+func (plug *PlugDJ) do(method, endpoint string, query url.Values, payload []byte) (*http.Response, error) {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+
+	u := plug.getAPIURL() + endpoint
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	plug.config.limiter.wait()
+	plug.endpointLimiter(endpoint).wait()
+
+	resp, err := plug.web.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
 	// If the status code is not 200, error away
 	if resp.StatusCode != 200 {
-		quickread(resp.Body)
-		resp.Body.Close()
-		return nil, ErrUnknownResponse{resp, endpoint}
+		defer resp.Body.Close()
+		return nil, newAPIError(resp, endpoint)
 	}
 
 	return resp, nil
 }
 
-// apiResponse is a struct for
+// Get makes a get request to the plug API
+func (plug *PlugDJ) Get(endpoint string) (*http.Response, error) {
+	return plug.Request("GET", endpoint, nil, nil)
+}
+
+// Post makes a post request with the data provided as json to the plug API
+func (plug *PlugDJ) Post(endpoint string, data interface{}) (*http.Response, error) {
+	return plug.Request("POST", endpoint, nil, data)
+}
+
+// Put makes a put request with the data provided as json to the plug API
+func (plug *PlugDJ) Put(endpoint string, data interface{}) (*http.Response, error) {
+	return plug.Request("PUT", endpoint, nil, data)
+}
+
+// Delete makes a delete request to the plug API
+func (plug *PlugDJ) Delete(endpoint string) (*http.Response, error) {
+	return plug.Request("DELETE", endpoint, nil, nil)
+}
+
+// GetData allows you to receive info as a struct
+func (plug *PlugDJ) GetData(endpoint string, data, meta interface{}) error {
+	return plug.RequestData("GET", endpoint, nil, nil, data, meta)
+}
+
+// PostData posts the body and receives the response as a struct
+func (plug *PlugDJ) PostData(endpoint string, body, data, meta interface{}) error {
+	return plug.RequestData("POST", endpoint, nil, body, data, meta)
+}
+
+// PutData puts the body and receives the response as a struct
+func (plug *PlugDJ) PutData(endpoint string, body, data, meta interface{}) error {
+	return plug.RequestData("PUT", endpoint, nil, body, data, meta)
+}
+
+// DeleteData deletes and receives the response as a struct
+func (plug *PlugDJ) DeleteData(endpoint string, data, meta interface{}) error {
+	return plug.RequestData("DELETE", endpoint, nil, nil, data, meta)
+}
+
+// apiEnvelope is a struct for
 // data sent by the plug.dj API
 type apiEnvelope struct {
 	// Note: why can't data be a []interface{} ??
@@ -153,68 +258,63 @@ type apiEnvelope struct {
 	Time   float32         `json:"time"`
 }
 
-func handleResponse(resp *http.Response, data interface{}, meta interface{}) error {
-	envelope := &apiEnvelope{}
-
-	// err := json.Unmarshal(quickread(resp.Body), envelope)
-	err := json.NewDecoder(resp.Body).Decode(envelope)
-	if err != nil {
-		return errors.Wrap(err, "could not decode response")
+// newAPIError reads the body of a failed response into an APIError.
+// plug usually still sends an envelope, but not always.
+func newAPIError(resp *http.Response, endpoint string) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Endpoint:   endpoint,
+		Response:   resp,
 	}
 
-	if envelope.Status != "ok" {
-		return &ErrDataRequestError{envelope, fmt.Sprintf("%+v", resp.Request.Host)}
-	}
-
-	err = json.Unmarshal([]byte(envelope.Data), data)
+	// error bodies are small, don't read more than we need
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
 	if err != nil {
-		return err
+		return apiErr
 	}
 
-	if meta != nil {
-		err = json.Unmarshal([]byte(envelope.Meta), meta)
-		if err != nil {
-			return err
-		}
+	envelope := &apiEnvelope{}
+	if json.Unmarshal(body, envelope) == nil && envelope.Status != "" {
+		apiErr.Status = envelope.Status
+		apiErr.Data = envelope.Data
 	}
 
-	return nil
+	return apiErr
 }
 
-// GetData allows you to receive info as a struct
-func (plug *PlugDJ) GetData(endpoint string, data interface{}, meta interface{}) error {
-	resp, err := plug.Get(endpoint)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+// handleResponse decodes the envelope in the response, putting
+// its data and meta into those given if they are not nil
+func handleResponse(resp *http.Response, endpoint string, data, meta interface{}) error {
+	envelope := &apiEnvelope{}
 
-	if err := handleResponse(resp, &data, &meta); err != nil {
-		return err
+	err := json.NewDecoder(resp.Body).Decode(envelope)
+	if err != nil {
+		return errors.Wrap(err, "could not decode response")
 	}
 
-	return nil
-}
-
-// Post makes a post request with the map provided as json to the plug API
-func (plug *PlugDJ) Post(endpoint string, data map[string]string) (*http.Response, error) {
-	body, err := json.Marshal(data)
-	if err != nil {
-		return nil, err
+	if envelope.Status != "ok" {
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Endpoint:   endpoint,
+			Status:     envelope.Status,
+			Data:       envelope.Data,
+			Response:   resp,
+		}
 	}
 
-	resp, err := plug.web.Post(plug.getAPIURL()+endpoint, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return nil, err
+	if data != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, data); err != nil {
+			return err
+		}
 	}
 
-	// If the status code is not 200, error away
-	if resp.StatusCode != 200 {
-		resp.Body.Close()
-		return nil, ErrUnknownResponse{resp, endpoint}
+	if meta != nil && len(envelope.Meta) > 0 {
+		if err := json.Unmarshal(envelope.Meta, meta); err != nil {
+			return err
+		}
 	}
 
-	return resp, nil
+	return nil
 }
 
 func (plug *PlugDJ) getAPIURL() string {
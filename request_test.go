@@ -0,0 +1,78 @@
+package plugapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestRequestData(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		var body []int
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if r.Method != "PUT" || r.URL.Query().Get("page") != "3" {
+			t.Errorf("request = %s %s, want a PUT of page 3", r.Method, r.URL)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content type = %q, want json", r.Header.Get("Content-Type"))
+		}
+		if len(body) != 2 || body[1] != 5 {
+			t.Errorf("body = %v, want [4 5]", body)
+		}
+
+		w.Write([]byte(`{"status":"ok","data":[{"id":1}],"meta":{"total":10}}`))
+	})
+
+	var data []struct{ ID int }
+	var meta struct{ Total int }
+	err := plug.RequestData("PUT", "/test", url.Values{"page": {"3"}}, []int{4, 5}, &data, &meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data[0].ID != 1 || meta.Total != 10 {
+		t.Errorf("data = %+v, meta = %+v", data, meta)
+	}
+
+	// neither data nor meta have to be wanted
+	if err := plug.RequestData("PUT", "/test", url.Values{"page": {"3"}}, []int{4, 5}, nil, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequestWithoutBody(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 0 || r.Header.Get("Content-Type") != "" {
+			t.Errorf("%s has a body of %d bytes", r.Method, r.ContentLength)
+		}
+		writeEnvelope(w, "ok", []interface{}{})
+	})
+
+	if err := plug.DeleteData("/test/1", nil, nil); err != nil {
+		t.Error(err)
+	}
+	if err := plug.GetData("/test", nil, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeEnvelope(w, "notAuthorized", []string{"nope"})
+	})
+
+	err := plug.PostData("/test", map[string]bool{"on": true}, nil, nil)
+	if e, ok := asAPIError(err); !ok || e.Status != "notAuthorized" || e.Endpoint != "/test" {
+		t.Errorf("err = %#v, want an APIError for notAuthorized", err)
+	}
+
+	err = plug.GetData("/missing", nil, nil)
+	if e, ok := asAPIError(err); !ok || e.StatusCode != http.StatusNotFound {
+		t.Errorf("err = %#v, want an APIError for the 404", err)
+	}
+}
@@ -0,0 +1,178 @@
+package plugapi
+
+import (
+	"errors"
+	log "github.com/Sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit allows at most Requests requests every Period
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Rate limited requests are retried at least this many times, even if
+// Config.Retry wouldn't, and wait at least rateLimitDelay between tries
+const (
+	rateLimitRetries = 3
+	rateLimitDelay   = time.Second
+)
+
+// The longest we wait when plug asks us to with Retry-After
+const maxRetryAfter = time.Minute
+
+// idempotent methods can safely be sent twice
+var idempotent = map[string]bool{
+	"GET":    true,
+	"HEAD":   true,
+	"PUT":    true,
+	"DELETE": true,
+}
+
+// retry calls fn until it succeeds or we shouldn't try again.
+//
+//   - any request is retried when plug says we are rate limited, at
+//     least rateLimitRetries times (whatever the policy says), waiting
+//     for as long as plug asks us to (Retry-After, up to maxRetryAfter)
+//   - idempotent requests are also retried on network and server errors
+//   - when plug says we aren't logged in, we log in again and retry once
+func (plug *PlugDJ) retry(method, endpoint string, fn func() error) error {
+	policy := plug.config.Retry
+	reauthenticated := false
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		apiErr, isAPIErr := asAPIError(err)
+
+		// logging in can't fix logging in
+		if !reauthenticated && endpoint != AuthLoginEndpoint && errors.Is(err, ErrNotAuthorized) && plug.sessionExpired(apiErr) {
+			reauthenticated = true
+			plug.Log.WithField("endpoint", endpoint).Infoln("Session expired, logging in again...")
+			if authErr := plug.reauthenticate(); authErr != nil {
+				plug.Log.WithField("error", authErr).Warnln("could not log in again")
+				return err
+			}
+
+			attempt-- // this one doesn't count
+			continue
+		}
+
+		rateLimited := errors.Is(err, ErrRateLimited)
+		if !policy.allows(attempt) && !(rateLimited && attempt < rateLimitRetries) {
+			return err
+		}
+
+		delay := policy.delay(attempt)
+		switch {
+		case rateLimited:
+			if after, ok := retryAfter(apiErr); ok {
+				delay = after
+			} else if delay < rateLimitDelay {
+				delay = rateLimitDelay
+			}
+		case !idempotent[method]:
+			return err
+		case isAPIErr && !errors.Is(err, ErrServerError):
+			// plug understood us and said no, asking again won't help
+			return err
+		}
+
+		plug.Log.WithFields(log.Fields{
+			"endpoint": endpoint,
+			"attempt":  attempt + 1,
+			"delay":    delay,
+		}).Debugln("retrying request", err)
+		time.Sleep(delay)
+	}
+}
+
+// retryAfter reads the Retry-After header, which is either
+// a number of seconds or an HTTP date, up to maxRetryAfter
+func retryAfter(apiErr *APIError) (time.Duration, bool) {
+	d, ok := parseRetryAfter(apiErr)
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+	return d, ok
+}
+
+func parseRetryAfter(apiErr *APIError) (time.Duration, bool) {
+	if apiErr == nil || apiErr.Response == nil {
+		return 0, false
+	}
+
+	header := apiErr.Response.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// sessionExpired decides whether a notAuthorized reply means we aren't
+// logged in anymore (rather than just not being allowed to do something)
+func (plug *PlugDJ) sessionExpired(apiErr *APIError) bool {
+	return !plug.loggedIn() || (apiErr != nil && apiErr.StatusCode == http.StatusUnauthorized)
+}
+
+// loggedIn checks for the "session" cookie plug gives us once logged in
+func (plug *PlugDJ) loggedIn() bool {
+	u, _ := url.Parse(plug.config.BaseURL) // we ignore parse errors...
+	for _, cookie := range plug.web.Jar.Cookies(u) {
+		if cookie.Name == "session" {
+			return true
+		}
+	}
+	return false
+}
+
+// reauthenticate logs in again. Many requests may find out that our
+// session expired at once, but only one of them needs to log in.
+func (plug *PlugDJ) reauthenticate() error {
+	plug.authLock.Lock()
+	defer plug.authLock.Unlock()
+
+	if time.Since(plug.authTime) < time.Second*5 {
+		return nil
+	}
+
+	if err := plug.authenticateUser(); err != nil {
+		return err
+	}
+
+	plug.authTime = time.Now()
+	return nil
+}
+
+// endpointLimiter finds the limiter for the endpoint, the one
+// for the longest matching prefix in Config.EndpointLimits
+func (plug *PlugDJ) endpointLimiter(endpoint string) *limiter {
+	var best *limiter
+	bestLen := -1
+	for prefix, l := range plug.endpointLimiters {
+		if strings.HasPrefix(endpoint, prefix) && len(prefix) > bestLen {
+			best, bestLen = l, len(prefix)
+		}
+	}
+	return best
+}
@@ -0,0 +1,135 @@
+package plugapi
+
+import (
+	"errors"
+	log "github.com/Sirupsen/logrus"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	withHeader := func(value string) *APIError {
+		resp := &http.Response{Header: http.Header{}}
+		if value != "" {
+			resp.Header.Set("Retry-After", value)
+		}
+		return &APIError{StatusCode: http.StatusTooManyRequests, Response: resp}
+	}
+
+	tests := []struct {
+		err  *APIError
+		want time.Duration
+		ok   bool
+	}{
+		{nil, 0, false},
+		{&APIError{}, 0, false},
+		{withHeader(""), 0, false},
+		{withHeader("3"), 3 * time.Second, true},
+		{withHeader("0"), 0, true},
+		{withHeader("-1"), 0, false},
+		{withHeader("soon"), 0, false},
+		{withHeader(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)), 0, true},
+		{withHeader("86400"), maxRetryAfter, true},
+		{withHeader(time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat)), maxRetryAfter, true},
+	}
+
+	for _, test := range tests {
+		got, ok := retryAfter(test.err)
+		if got != test.want || ok != test.ok {
+			t.Errorf("retryAfter(%v) = %s, %t, want %s, %t", test.err, got, ok, test.want, test.ok)
+		}
+	}
+
+	// dates in the future are however long until then
+	future := withHeader(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if got, ok := retryAfter(future); !ok || got <= 0 || got > time.Minute {
+		t.Errorf("retryAfter(a minute from now) = %s, %t", got, ok)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	serverError := &APIError{StatusCode: http.StatusBadGateway}
+	notFound := &APIError{StatusCode: http.StatusNotFound}
+	network := errors.New("connection reset")
+
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		calls  int // with three attempts allowed
+	}{
+		{"get server error", "GET", serverError, 4},
+		{"get network error", "GET", network, 4},
+		{"get not found", "GET", notFound, 1},
+		{"post server error", "POST", serverError, 1},
+	}
+
+	for _, test := range tests {
+		plug := &PlugDJ{config: &Config{Retry: RetryPolicy{MaxAttempts: 3}}, Log: log.New()}
+
+		calls := 0
+		err := plug.retry(test.method, "/test", func() error {
+			calls++
+			return test.err
+		})
+
+		if err != test.err {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.err)
+		}
+		if calls != test.calls {
+			t.Errorf("%s: %d calls, want %d", test.name, calls, test.calls)
+		}
+	}
+}
+
+func TestRetryRateLimitedByDefault(t *testing.T) {
+	rateLimited := &APIError{StatusCode: http.StatusTooManyRequests, Response: &http.Response{Header: http.Header{"Retry-After": {"0"}}}}
+	plug := &PlugDJ{config: &Config{}, Log: log.New()}
+
+	calls := 0
+	err := plug.retry("POST", "/test", func() error {
+		calls++
+		return rateLimited
+	})
+
+	if err != rateLimited {
+		t.Errorf("err = %v, want %v", err, rateLimited)
+	}
+	if calls != rateLimitRetries+1 {
+		t.Errorf("%d calls, want %d", calls, rateLimitRetries+1)
+	}
+
+	// a policy allowing more is followed
+	plug.config.Retry = RetryPolicy{MaxAttempts: 5}
+	calls = 0
+	plug.retry("POST", "/test", func() error {
+		calls++
+		return rateLimited
+	})
+	if calls != 6 {
+		t.Errorf("%d calls, want 6", calls)
+	}
+}
+
+func TestEndpointLimiter(t *testing.T) {
+	plug := &PlugDJ{endpointLimiters: map[string]*limiter{
+		"/rooms":      newLimiter(RateLimit{Requests: 1, Period: time.Second}),
+		"/rooms/join": newLimiter(RateLimit{Requests: 2, Period: time.Second}),
+	}}
+
+	tests := []struct {
+		endpoint string
+		want     *limiter
+	}{
+		{"/rooms/join", plug.endpointLimiters["/rooms/join"]},
+		{"/rooms/state", plug.endpointLimiters["/rooms"]},
+		{"/users/me", nil},
+	}
+
+	for _, test := range tests {
+		if got := plug.endpointLimiter(test.endpoint); got != test.want {
+			t.Errorf("endpointLimiter(%q) = %p, want %p", test.endpoint, got, test.want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package plugapi
 
-import "sync"
+import (
+	"strings"
+	"sync"
+)
 
 // Room contains metadata about the room
 // TODO: Unexport this.
@@ -23,16 +26,33 @@ type Room struct {
 		WelcomeMessage   string `json:"welcomemessage"` // the welcome message on entering
 	} `json:"meta"`
 	// Mutes interface{} `json:"mutes"`
-	Playback *Playback `json:"playback"`
-	users    []User    // Not caught by json because it's unexported
-	// Votes interface{} `json:"votes"`
+	Playback *Playback  `json:"playback"`
+	users    []User     // Not caught by json because it's unexported
+	cache    *userCache // Where to look for users that aren't in the room
+	departed *userCache // Users that left recently
+
+	// how the current play is doing, see score
+	votes   map[int]int // 1 for a woot, -1 for a meh
+	grabs   map[int]bool
+	skipped bool
 }
 
 type roomJson struct {
 	*Room
-	Role  int    `json:"role"` // OUR ROLE IN THE ROOM << DO NOT USE
-	Users []User `json:"users"`
-	// Votes interface{} `json:"votes"`
+	Role  int         `json:"role"` // OUR ROLE IN THE ROOM << DO NOT USE
+	Users []User      `json:"users"`
+	Votes map[int]int `json:"votes"`
+	Grabs map[int]int `json:"grabs"`
+}
+
+// room returns the Room, along with the votes and grabs on its current play
+func (j *roomJson) room() *Room {
+	j.Room.votes = j.Votes
+	j.Room.grabs = make(map[int]bool)
+	for id := range j.Grabs {
+		j.Room.grabs[id] = true
+	}
+	return j.Room
 }
 
 func gatherUsers(r *Room, users []int) []User {
@@ -52,20 +72,14 @@ func gatherUsers(r *Room, users []int) []User {
 
 func (r *Room) getDJ() *User {
 	r.RLock()
+	dj := r.Booth.CurrentDJ
+	r.RUnlock()
 
-	if r.Booth.CurrentDJ <= 0 {
+	if dj <= 0 {
 		return nil
 	}
 
-	// user := r.GetUser(r.Booth.CurrentDJ)
-	// TODO: What does cacheUser do here?
-	// (see room.js)
-
-	dj := r.Booth.CurrentDJ
-
-	r.RUnlock()
 	return r.getUser(dj)
-
 }
 
 func (r *Room) getUser(id int) *User {
@@ -86,41 +100,99 @@ func (r *Room) getUser(id int) *User {
 		}
 	}
 
+	// They might have just left, or been looked up before
+	if user, ok := r.departed.get(id); ok {
+		return user
+	}
+	if user, ok := r.cache.get(id); ok {
+		return user
+	}
+
 	// Couldn't find it, sorry.
 	return nil
 }
 
+func (r *Room) getUserByName(name string) *User {
+	r.RLock()
+	defer r.RUnlock()
+
+	// Linear search for the user, plug usernames are case insensitive
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, name) {
+			return &user
+		}
+	}
+
+	return nil
+}
+
 func (r *Room) getDJs() []User {
-	return gatherUsers(r, r.Booth.WaitingDJs)
+	r.RLock()
+	waiting := append([]int(nil), r.Booth.WaitingDJs...)
+	r.RUnlock()
+
+	return gatherUsers(r, waiting)
 }
 
-func (r *Room) removeUser(id int) (u *User) {
-	r.Lock()
-	defer r.Unlock()
+// waitlistPosition returns where the user is in the waitlist,
+// starting at zero, or -1 if they aren't in it
+func (r *Room) waitlistPosition(id int) int {
+	r.RLock()
+	defer r.RUnlock()
 
-	index := -1
-	for i, user := range r.users {
+	for i, uid := range r.Booth.WaitingDJs {
+		if uid == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// inRoom checks if the user is actually in the room, unlike
+// getUser which also finds users that have left
+func (r *Room) inRoom(id int) bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, user := range r.users {
 		if user.ID == id {
-			index = i
-			u = &user
-			break
+			return true
 		}
 	}
+	return false
+}
 
-	if index == -1 {
-		return nil
+// removeUser removes the user from the room,
+// remembering them for a while in case they come up again
+func (r *Room) removeUser(id int) *User {
+	r.Lock()
+	defer r.Unlock()
+
+	u := r.removeUserLocked(id)
+	if u != nil {
+		r.departed.add(*u)
 	}
+	return u
+}
 
-	r.users = append(r.users[:index], r.users[index+1:]...)
-	return
+// removeUserLocked removes the user, r must already be locked
+func (r *Room) removeUserLocked(id int) *User {
+	for i, user := range r.users {
+		if user.ID == id {
+			r.users = append(r.users[:i], r.users[i+1:]...)
+			return &user
+		}
+	}
+	return nil
 }
 
 func (r *Room) addUser(u User) {
 	r.Lock()
 	defer r.Unlock()
 
-	r.removeUser(u.ID)
+	r.removeUserLocked(u.ID)
 	r.users = append(r.users, u)
+	r.departed.remove(u.ID) // they're back
 }
 
 func (r *Room) GetUsers() (u []User) {
@@ -133,6 +205,78 @@ func (r *Room) GetUsers() (u []User) {
 	return
 }
 
+// replace makes r the room we are now in, keeping its caches.
+// Rooms are replaced in place so that anyone holding r sees
+// the new room, without needing a lock around the pointer.
+func (r *Room) replace(room *Room, users []User) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.Booth = room.Booth
+	r.Meta = room.Meta
+	r.Playback = room.Playback
+	r.users = users
+	r.votes = room.votes
+	r.grabs = room.grabs
+	r.skipped = room.skipped
+}
+
+// vote records a woot (1) or meh (-1) on the current play
+func (r *Room) vote(userID, vote int) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.votes == nil {
+		r.votes = make(map[int]int)
+	}
+	r.votes[userID] = vote
+}
+
+// grab records someone grabbing the current play
+func (r *Room) grab(userID int) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.grabs == nil {
+		r.grabs = make(map[int]bool)
+	}
+	r.grabs[userID] = true
+}
+
+// skip records the play being skipped, if it is still the current one.
+// plug doesn't say which play others skipped, and by the time we hear
+// of it the next play may have started, so only our own skips count.
+func (r *Room) skip(historyID string) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.Playback != nil && r.Playback.HistoryID == historyID {
+		r.skipped = true
+	}
+}
+
+// score returns how the current play has done so far
+func (r *Room) score() PlayScore {
+	r.RLock()
+	defer r.RUnlock()
+
+	score := PlayScore{
+		Grabs:     len(r.grabs),
+		Listeners: len(r.users),
+	}
+	for _, vote := range r.votes {
+		if vote > 0 {
+			score.Positive++
+		} else if vote < 0 {
+			score.Negative++
+		}
+	}
+	if r.skipped {
+		score.Skipped = 1
+	}
+	return score
+}
+
 // Warning: This isn't copied
 func (r *Room) SetUsers(u []User) {
 	r.Lock()
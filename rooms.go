@@ -0,0 +1,126 @@
+package plugapi
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// RoomInfo is a room as it appears in the room list
+type RoomInfo struct {
+	CID        string `json:"cid"` // cid of the media playing
+	DJ         string `json:"dj"`  // username of the current DJ
+	Favorite   bool   `json:"favorite"`
+	Guests     int    `json:"guests"`
+	Host       string `json:"host"` // username of the room owner
+	ID         int    `json:"id"`
+	Image      string `json:"image"` // thumbnail of the media playing
+	Media      string `json:"media"` // "author - title" of the media playing
+	Name       string `json:"name"`
+	Population int    `json:"population"`
+	Private    bool   `json:"private"`
+	Slug       string `json:"slug"`
+}
+
+// RoomChangePayload is sent when SwitchRoom moves us to another room
+type RoomChangePayload struct {
+	From string // slug of the room we left
+	To   string // slug of the room we are now in
+}
+
+// GetRooms searches the room list. An empty query lists the most popular
+// rooms. Pages start at zero and plug returns at most 50 rooms per page.
+func (plug *PlugDJ) GetRooms(query string, page, limit int) ([]RoomInfo, error) {
+	return plug.listRooms(RoomListEndpoint, query, page, limit)
+}
+
+// GetFavoriteRooms searches the favorite rooms of the logged in user,
+// with the same query and paging as GetRooms
+func (plug *PlugDJ) GetFavoriteRooms(query string, page, limit int) ([]RoomInfo, error) {
+	return plug.listRooms(RoomFavoritesEndpoint, query, page, limit)
+}
+
+func (plug *PlugDJ) listRooms(endpoint, query string, page, limit int) ([]RoomInfo, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(limit))
+
+	var rooms []RoomInfo
+	if err := plug.RequestData("GET", endpoint, params, nil, &rooms, nil); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// AddFavoriteRoom adds the room to the favorites of the logged in user
+func (plug *PlugDJ) AddFavoriteRoom(roomID int) error {
+	return plug.PostData(RoomFavoritesEndpoint, map[string]int{"id": roomID}, nil, nil)
+}
+
+// RemoveFavoriteRoom removes the room from the favorites of the logged in user
+func (plug *PlugDJ) RemoveFavoriteRoom(roomID int) error {
+	return plug.DeleteData(RoomFavoritesEndpoint+"/"+strconv.Itoa(roomID), nil, nil)
+}
+
+// LeaveRoom leaves the room we are in. plug has no way of leaving a room
+// over REST, instead we leave once our socket has gone away.
+func (plug *PlugDJ) LeaveRoom() error {
+	if plug.State() != ConnectedState {
+		return errors.New("plugapi: not in a room")
+	}
+
+	plug.setLeft(true)
+	plug.setState(DisconnectedState)
+	plug.closeSocket()
+	plug.resetRoom()
+	return nil
+}
+
+// SwitchRoom moves us from the room we are in to another room,
+// keeping our socket connection. A RoomChangeEvent is sent once
+// we are in the new room and its state has been loaded.
+func (plug *PlugDJ) SwitchRoom(slug string) error {
+	if plug.State() != ConnectedState {
+		return errors.New("plugapi: not in a room")
+	}
+
+	if !plug.startConnecting() {
+		return errors.New("plugapi: already connecting to a room")
+	}
+	defer plug.doneConnecting()
+
+	plug.Room.RLock()
+	from := plug.Room.Meta.Slug
+	plug.Room.RUnlock()
+
+	// forget everything about the old room so that nothing
+	// from it can leak into the new one if joining fails
+	plug.resetRoom()
+
+	// we aren't in any room now, so we shouldn't look like
+	// we are (a Manager rejoins disconnected sessions)
+	if err := plug.joinRoom(slug); err != nil {
+		plug.setState(DisconnectedState)
+		plug.closeSocket()
+		return err
+	}
+
+	plug.emitEvent(RoomChangeEvent, RoomChangePayload{From: from, To: slug})
+	return nil
+}
+
+// resetRoom forgets everything we know about the room we were in.
+// The room is emptied in place, as plug.Room is used without locking.
+func (plug *PlugDJ) resetRoom() {
+	plug.Room.replace(&Room{}, nil)
+
+	plug.historyLock.Lock()
+	plug.History = nil
+	plug.historyFetched = false
+	plug.historyLock.Unlock()
+
+	plug.resetActivity(nil)
+	plug.forgetSpots()
+	plug.scheduleTrackTimers(nil)
+}
@@ -0,0 +1,90 @@
+package plugapi
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"net/http"
+	"testing"
+)
+
+func TestGetRooms(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/_"+RoomListEndpoint || q.Get("q") != "chill & co" || q.Get("page") != "2" || q.Get("limit") != "10" {
+			t.Errorf("request = %s, want page 2 of 10 searching for %q", r.URL, "chill & co")
+		}
+		writeEnvelope(w, "ok", []RoomInfo{{ID: 1, Slug: "chill", Population: 42}})
+	})
+
+	rooms, err := plug.GetRooms("chill & co", 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 1 || rooms[0].Slug != "chill" || rooms[0].Population != 42 {
+		t.Errorf("rooms = %+v, want only chill", rooms)
+	}
+}
+
+func TestFavoriteRooms(t *testing.T) {
+	var requests []string
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		writeEnvelope(w, "ok", []interface{}{})
+	})
+
+	if err := plug.AddFavoriteRoom(7); err != nil {
+		t.Fatal(err)
+	}
+	if err := plug.RemoveFavoriteRoom(7); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"POST /_" + RoomFavoritesEndpoint, "DELETE /_" + RoomFavoritesEndpoint + "/7"}
+	if len(requests) != 2 || requests[0] != want[0] || requests[1] != want[1] {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
+
+func TestLeaveRoomNotInRoom(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+	})
+
+	if err := plug.LeaveRoom(); err == nil {
+		t.Error("left a room we weren't in")
+	}
+	if err := plug.SwitchRoom("elsewhere"); err == nil {
+		t.Error("switched from a room we weren't in")
+	}
+}
+
+func TestResetRoomInPlace(t *testing.T) {
+	plug := newPlug(Config{Log: log.New()})
+	room := plug.Room
+	room.SetUsers([]User{{ID: 2, Username: "alice"}})
+	room.Meta.Slug = "old"
+	plug.History = []HistoryItem{{ID: "1"}}
+
+	// anyone still holding the room sees it emptied
+	plug.resetRoom()
+	if plug.Room != room {
+		t.Fatal("plug.Room was swapped for another room")
+	}
+	if room.Meta.Slug != "" || len(room.GetUsers()) != 0 || len(plug.History) != 0 {
+		t.Errorf("room = %+v, want it forgotten", room)
+	}
+}
+
+func TestSendWithoutSocket(t *testing.T) {
+	plug := newPlug(Config{Log: log.New()})
+
+	// closing and sending at once must not race on the socket
+	done := make(chan struct{})
+	go func() {
+		plug.closeSocket()
+		close(done)
+	}()
+	if err := plug.SendChat("hi"); err == nil {
+		t.Error("sent chat without a socket")
+	}
+	<-done
+}
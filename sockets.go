@@ -7,6 +7,7 @@ import (
 	"github.com/gorilla/websocket"
 	"net/http"
 	// "strconv"
+	"sync/atomic"
 	"time"
 )
 
@@ -53,25 +54,15 @@ func (plug *PlugDJ) connectSocket() error {
 		panic("impossible situation - check variables map key")
 	}
 
-	// Format of the time used (manually composed)
-	// see: https://golang.org/src/time/format.go
-	format := "2006-01-02 15:04:05.000000"
-	theirTime, err := time.Parse(format, timeStr)
+	theirTime, err := parseTimestamp(timeStr)
 	if err != nil {
 		plug.Log.WithField("_st", variables[",_st"]).Warnln("could not parse correctly")
 		return errors.New("plugapi: could not parse _st correctly")
 	}
 
-	// offset the time, store it in seconds
-	// note: Seconds() returns a float, and int() truncates it
-	offset := int(time.Now().Sub(theirTime).Seconds())
-	plug.Log.WithField("offset", offset).Debugln("Received time offset from plug.dj server")
-	// fmt.Println(time.Now(), theirTime)
-
-	// plugdj runs in their own timezone... valve time
-	// now we can use this Location to handle times
-	// correctly everywhere on our bot
-	plug.location = time.FixedZone("plugdj", offset)
+	// _st is precise, so it replaces whatever we had before
+	plug.clock.set(theirTime)
+	plug.Log.WithField("skew", plug.clock.Skew()).Debugln("Received time from plug.dj server")
 
 	// make a header with our origin...
 	header := make(http.Header)
@@ -84,98 +75,204 @@ func (plug *PlugDJ) connectSocket() error {
 		plug.Log.WithFields(log.Fields{
 			"socketURL": plug.config.SocketURL,
 			"baseURL":   plug.config.BaseURL,
-		}).Fatalf("websocket.Dial encountered error>> %s", err)
+		}).Errorf("websocket.Dial encountered error>> %s", err)
 		return err
 	}
 
 	// add the websocket to the plug obj
+	plug.writeLock.Lock()
 	plug.wss = wss
+	plug.writeLock.Unlock()
+
+	// a closer so that we can close any goroutines we have created
+	closer := make(chan struct{})
+	ack := make(chan error)
+	plug.connLock.Lock()
+	plug.closer, plug.ack = closer, ack
+	plug.connLock.Unlock()
 
 	// start listening
-	plug.ack = make(chan error)
-	go plug.listen()
+	plug.startHeartbeat(wss, closer)
+	go plug.listen(wss, closer)
+	go plug.watchIdle(closer)
 
 	// Now we try to authenticate with our auth code...
 	plug.Log.Debugln("Authenticating with our websocket...")
 	err = plug.sendSocketJSON("auth", plug.authCode)
 	if err != nil {
 		plug.Log.Warnf("Failed to authenticate with our websocket >> %s\n", err)
+		plug.dropSocket(wss)
 		return err
 	}
 
 	select {
 	// wait until we have successfully authenticated
-	case err, failed := <-plug.ack:
+	case err, failed := <-ack:
 		if failed {
+			plug.dropSocket(wss)
 			return err
 		}
 		return nil
 	// or five seconds have passed
 	case <-time.After(time.Second * 5):
+		plug.dropSocket(wss)
 		return errors.New("could not authenticate with WS server")
 	}
 }
 
+// connection returns the closer and ack channel of our current connection
+func (plug *PlugDJ) connection() (closer chan struct{}, ack chan error) {
+	plug.connLock.Lock()
+	defer plug.connLock.Unlock()
+
+	return plug.closer, plug.ack
+}
+
+// socket returns our socket, or nil if we don't have one
+func (plug *PlugDJ) socket() *websocket.Conn {
+	plug.writeLock.Lock()
+	defer plug.writeLock.Unlock()
+
+	return plug.wss
+}
+
+// dropSocket closes a socket we have given up on,
+// forgetting about it if it is still our socket
+func (plug *PlugDJ) dropSocket(wss *websocket.Conn) {
+	if wss == nil {
+		return
+	}
+
+	plug.writeLock.Lock()
+	if plug.wss == wss {
+		plug.wss = nil
+	}
+	plug.writeLock.Unlock()
+
+	wss.Close()
+}
+
+// startConnecting marks us as connecting to a room, returning false
+// if we already are. doneConnecting must be called once we are done.
+func (plug *PlugDJ) startConnecting() bool {
+	return atomic.CompareAndSwapInt32(&plug.connecting, 0, 1)
+}
+
+func (plug *PlugDJ) doneConnecting() {
+	atomic.StoreInt32(&plug.connecting, 0)
+}
+
 func (plug *PlugDJ) sendSocketJSON(action string, data interface{}) error {
+	// only one goroutine may write to a socket at a time,
+	// and closeSocket can't take it away while we do
+	plug.writeLock.Lock()
+	defer plug.writeLock.Unlock()
+
+	wss := plug.wss
+	if wss == nil {
+		return errors.New("plugapi: not connected to the socket")
+	}
+
 	body := socketMessage{
 		Action:    action,
 		Parameter: data,
-		Time:      time.Now().In(plug.location).Unix(), // NOTE: NEEDS TO BE NUMBER NOT STRING
+		Time:      plug.ServerNow().Unix(), // NOTE: NEEDS TO BE NUMBER NOT STRING
 	}
 
-	// plug.Log.WithField("body", body).Debugln("Sending WS data")
-	return plug.wss.WriteJSON(body)
+	frame, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	plug.traceFrame("out", frame)
+	plug.record(FrameOut, frame)
+	plug.updateStats(func(stats *Stats) { stats.FramesOut++ })
+
+	return wss.WriteMessage(websocket.TextMessage, frame)
 }
 
-func (plug *PlugDJ) listen() {
-	defer plug.wss.Close()
-	defer close(plug.closer)
+// listen reads from the socket until it closes. Each connection has
+// its own listener, so the connection and closer are passed in rather
+// than read from plug (they are replaced when we reconnect).
+func (plug *PlugDJ) listen(wss *websocket.Conn, closer chan struct{}) {
+	// once we've stopped, see if we should reconnect
+	heartbeatMissed := false
+	defer func() { go plug.handleDisconnect(heartbeatMissed) }()
+	defer wss.Close()
+	defer close(closer)
 	for {
-		_, data, err := plug.wss.ReadMessage()
-		if err != nil {
+		_, data, err := wss.ReadMessage()
+		if isTimeout(err) {
+			heartbeatMissed = true
+			stats := plug.Stats()
+			plug.Log.WithField("lastFrame", stats.LastFrame).Warnln("socket went quiet, heartbeat missed")
+			plug.emitEvent(HeartbeatMissedEvent, HeartbeatMissedPayload{
+				LastHeartbeat: stats.LastHeartbeat,
+				LastFrame:     stats.LastFrame,
+			})
+			return
+		} else if err != nil {
 			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
 				plug.Log.Errorln("socket read error:", err)
-				// TODO: Should we attempt to re-establish a connection?
 			}
 			return
 		}
 
+		plug.receivedFrame(wss, data)
+
+		plug.traceFrame("in", data)
+		plug.record(FrameIn, data)
+
 		// ignore messages with just "h"
-		if (len(data) == 1) && (data[0] == 'h') {
+		if isHeartbeat(data) {
 			continue
 		}
 
-		// for some reason the server may send multiple messages
-		var messages []json.RawMessage
-		if err := json.Unmarshal([]byte(data), &messages); err != nil {
-			plug.Log.WithField("data", string(data)).Warnf("ws: could not unmarshal socket array>> %s\n", err)
+		messages, err := plug.parseFrame(data)
+		if err != nil {
 			return
 		}
 
-		for _, buf := range messages {
-			// plug.Log.Debugln(string(buf))
-			go func(buf json.RawMessage) {
-
-				// init a message with our json.RawMessage
-				// Param so that we can read it later
-				msg := socketMessage{
-					Parameter: new(json.RawMessage),
-				}
-
-				// unmarshal it
-				if err := json.Unmarshal(buf, &msg); err != nil {
-					plug.Log.WithField("data", string(data)).Warnf("ws: could not unmarshal>> %s\n", err)
-					return
-				}
-
-				// do some "depointering" so that we
-				// don't have to do it when handling it
-				msg.Parameter = *msg.Parameter.(*json.RawMessage)
-
-				// send it off to our socket message handler
-				handleAction(plug, msg)
-			}(buf)
+		for _, msg := range messages {
+			// send it off to our socket message handler
+			go handleAction(plug, msg)
 		}
+	}
+}
 
+// isHeartbeat checks for the "h" frames plug sends to keep us alive
+func isHeartbeat(data []byte) bool {
+	return (len(data) == 1) && (data[0] == 'h')
+}
+
+// parseFrame turns a frame from the socket into the messages within
+func (plug *PlugDJ) parseFrame(data []byte) ([]socketMessage, error) {
+	// for some reason the server may send multiple messages
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		plug.Log.WithField("data", string(data)).Warnf("ws: could not unmarshal socket array>> %s\n", err)
+		return nil, err
+	}
+
+	messages := make([]socketMessage, 0, len(raw))
+	for _, buf := range raw {
+		// init a message with our json.RawMessage
+		// Param so that we can read it later
+		msg := socketMessage{
+			Parameter: new(json.RawMessage),
+		}
+
+		// unmarshal it
+		if err := json.Unmarshal(buf, &msg); err != nil {
+			plug.Log.WithField("data", string(buf)).Warnf("ws: could not unmarshal>> %s\n", err)
+			continue
+		}
+
+		// do some "depointering" so that we
+		// don't have to do it when handling it
+		msg.Parameter = *msg.Parameter.(*json.RawMessage)
+		messages = append(messages, msg)
 	}
+
+	return messages, nil
 }
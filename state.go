@@ -0,0 +1,199 @@
+package plugapi
+
+import (
+	"time"
+)
+
+// Retries of reconnect are never closer together than this,
+// so that a policy without a delay can't spin
+const minReconnectDelay = time.Second
+
+// How we reconnect after a missed heartbeat when Config.Reconnect
+// is the zero value. plug is usually still there when the socket just
+// goes quiet, so giving up straight away would be a waste.
+var defaultHeartbeatReconnect = RetryPolicy{
+	MaxAttempts: 5,
+	Delay:       time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// State is the state of our connection to plug
+type State int
+
+// List of connection states
+const (
+	DisconnectedState State = iota // not connected to a room
+	ConnectingState                // JoinRoom is in progress
+	ConnectedState                 // in a room and listening
+	ReconnectingState              // lost the socket, trying to get it back
+	KilledState                    // another login ended our session
+	MaintenanceState               // plug is down for maintenance
+	ClosedState                    // Close has been called
+)
+
+func (s State) String() string {
+	switch s {
+	case DisconnectedState:
+		return "disconnected"
+	case ConnectingState:
+		return "connecting"
+	case ConnectedState:
+		return "connected"
+	case ReconnectingState:
+		return "reconnecting"
+	case KilledState:
+		return "killed"
+	case MaintenanceState:
+		return "maintenance"
+	case ClosedState:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// RetryPolicy decides how often and how quickly something is retried
+type RetryPolicy struct {
+	MaxAttempts int           // 0 means never retry, -1 means retry forever
+	Delay       time.Duration // wait before the first attempt
+	MaxDelay    time.Duration // the delay doubles every attempt up to this
+}
+
+// allows checks if we are allowed to make
+// this attempt (counting from zero)
+func (p RetryPolicy) allows(attempt int) bool {
+	return p.MaxAttempts < 0 || attempt < p.MaxAttempts
+}
+
+// delay returns how long to wait before the given attempt
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Delay
+	for i := 0; i < attempt; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return d
+}
+
+// State returns the current state of our connection
+func (plug *PlugDJ) State() State {
+	plug.stateLock.RLock()
+	defer plug.stateLock.RUnlock()
+
+	return plug.state
+}
+
+func (plug *PlugDJ) setState(state State) {
+	plug.stateLock.Lock()
+	defer plug.stateLock.Unlock()
+
+	plug.Log.WithField("state", state).Debugln("state changed")
+	plug.state = state
+}
+
+// hasLeft checks if we left our room with LeaveRoom,
+// and haven't joined another one since
+func (plug *PlugDJ) hasLeft() bool {
+	plug.stateLock.RLock()
+	defer plug.stateLock.RUnlock()
+
+	return plug.left
+}
+
+func (plug *PlugDJ) setLeft(left bool) {
+	plug.stateLock.Lock()
+	defer plug.stateLock.Unlock()
+
+	plug.left = left
+}
+
+// handleDisconnect is called whenever our socket stops listening.
+// Depending on why it happened we might try to get it back.
+func (plug *PlugDJ) handleDisconnect(heartbeatMissed bool) {
+	switch plug.State() {
+	case ConnectedState:
+		plug.reconnect(plug.reconnectPolicy(heartbeatMissed))
+	case MaintenanceState:
+		plug.reconnect(plug.config.MaintenanceRetry)
+	default:
+		// We either meant to disconnect (Close), or another
+		// login killed our session. Retrying would be rude.
+	}
+}
+
+// reconnectPolicy is how we try to get back a socket that dropped
+func (plug *PlugDJ) reconnectPolicy(heartbeatMissed bool) RetryPolicy {
+	policy := plug.config.Reconnect
+	if heartbeatMissed && policy == (RetryPolicy{}) {
+		return defaultHeartbeatReconnect
+	}
+	return policy
+}
+
+// reconnect tries to reconnect to the socket
+// and rejoin our room according to the policy
+func (plug *PlugDJ) reconnect(policy RetryPolicy) {
+	if !policy.allows(0) {
+		plug.setState(DisconnectedState)
+		return
+	}
+
+	// while we are reconnecting, sockets that we give up
+	// on must not start reconnecting all over again
+	plug.setState(ReconnectingState)
+
+	plug.Room.RLock()
+	slug := plug.Room.Meta.Slug
+	plug.Room.RUnlock()
+
+	for attempt := 0; policy.allows(attempt); attempt++ {
+		delay := policy.delay(attempt)
+		if attempt > 0 && delay < minReconnectDelay {
+			delay = minReconnectDelay
+		}
+		time.Sleep(delay)
+
+		// Close may have been called or our session
+		// killed while we were waiting to try again
+		if plug.State() != ReconnectingState {
+			return
+		}
+
+		if !plug.startConnecting() {
+			plug.Log.Debugln("already connecting, not reconnecting this time")
+			continue
+		}
+
+		plug.Log.WithField("attempt", attempt+1).Infoln("Reconnecting...")
+		if plug.reconnectOnce(slug) {
+			plug.doneConnecting()
+			plug.updateStats(func(stats *Stats) { stats.Reconnects++ })
+			return
+		}
+		plug.doneConnecting()
+	}
+
+	plug.Log.Errorln("giving up on reconnecting")
+	plug.setState(DisconnectedState)
+}
+
+// reconnectOnce connects to the socket and rejoins the room,
+// returning true if we are connected again
+func (plug *PlugDJ) reconnectOnce(slug string) bool {
+	if err := plug.connectSocket(); err != nil {
+		plug.Log.WithField("error", err).Warnln("could not reconnect to socket")
+		return false
+	}
+
+	if slug != "" {
+		if err := plug.joinRoom(slug); err != nil {
+			plug.Log.WithField("error", err).Warnln("could not rejoin room")
+			plug.dropSocket(plug.socket())
+			return false
+		}
+	}
+
+	plug.setState(ConnectedState)
+	return true
+}
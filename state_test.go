@@ -0,0 +1,140 @@
+package plugapi
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{RetryPolicy{Delay: time.Second}, 0, time.Second},
+		{RetryPolicy{Delay: time.Second}, 3, 8 * time.Second},
+		{RetryPolicy{Delay: time.Second, MaxDelay: 5 * time.Second}, 2, 4 * time.Second},
+		{RetryPolicy{Delay: time.Second, MaxDelay: 5 * time.Second}, 3, 5 * time.Second},
+		{RetryPolicy{Delay: time.Second, MaxDelay: 5 * time.Second}, 100, 5 * time.Second},
+		{RetryPolicy{}, 5, 0},
+	}
+
+	for _, test := range tests {
+		if got := test.policy.delay(test.attempt); got != test.want {
+			t.Errorf("%+v.delay(%d) = %s, want %s", test.policy, test.attempt, got, test.want)
+		}
+	}
+}
+
+func TestRetryPolicyAllows(t *testing.T) {
+	tests := []struct {
+		policy  RetryPolicy
+		attempt int
+		want    bool
+	}{
+		{RetryPolicy{MaxAttempts: 0}, 0, false},
+		{RetryPolicy{MaxAttempts: 2}, 1, true},
+		{RetryPolicy{MaxAttempts: 2}, 2, false},
+		{RetryPolicy{MaxAttempts: -1}, 1000, true},
+	}
+
+	for _, test := range tests {
+		if got := test.policy.allows(test.attempt); got != test.want {
+			t.Errorf("%+v.allows(%d) = %t, want %t", test.policy, test.attempt, got, test.want)
+		}
+	}
+}
+
+func TestHandleDisconnectWhileReconnecting(t *testing.T) {
+	plug := newPlug(Config{Log: log.New(), Reconnect: RetryPolicy{MaxAttempts: -1}})
+	plug.setState(ReconnectingState)
+
+	// a socket given up on by reconnect must not start another loop
+	done := make(chan struct{})
+	go func() {
+		plug.handleDisconnect(false)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handleDisconnect started reconnecting while already reconnecting")
+	}
+
+	if state := plug.State(); state != ReconnectingState {
+		t.Errorf("state = %s, want %s", state, ReconnectingState)
+	}
+}
+
+func TestReconnectPolicy(t *testing.T) {
+	custom := RetryPolicy{MaxAttempts: 2, Delay: time.Minute}
+	tests := []struct {
+		reconnect       RetryPolicy
+		heartbeatMissed bool
+		want            RetryPolicy
+	}{
+		{RetryPolicy{}, false, RetryPolicy{}},
+		{RetryPolicy{}, true, defaultHeartbeatReconnect},
+		{custom, false, custom},
+		{custom, true, custom},
+	}
+
+	for _, test := range tests {
+		plug := newPlug(Config{Log: log.New(), Reconnect: test.reconnect})
+		if got := plug.reconnectPolicy(test.heartbeatMissed); got != test.want {
+			t.Errorf("Reconnect %+v, heartbeat missed %t: policy = %+v, want %+v", test.reconnect, test.heartbeatMissed, got, test.want)
+		}
+	}
+}
+
+func TestStartConnecting(t *testing.T) {
+	plug := newPlug(Config{Log: log.New()})
+
+	var wg sync.WaitGroup
+	var won int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if plug.startConnecting() {
+				atomic.AddInt32(&won, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if won != 1 {
+		t.Errorf("%d connection attempts started at once, want 1", won)
+	}
+
+	plug.doneConnecting()
+	if !plug.startConnecting() {
+		t.Error("could not connect again once done")
+	}
+}
+
+func TestRoomReplacedInPlace(t *testing.T) {
+	plug := newPlug(Config{Log: log.New()})
+	room := plug.Room
+
+	joined := &Room{Booth: Booth{CurrentDJ: 2}}
+	joined.Meta.Slug = "new"
+	room.replace(joined, []User{{ID: 2, Username: "alice"}})
+
+	if plug.Room != room {
+		t.Fatal("plug.Room was swapped for another room")
+	}
+	if room.Meta.Slug != "new" || room.Booth.CurrentDJ != 2 {
+		t.Errorf("room = %+v, want the room we joined", room)
+	}
+	if dj := room.getDJ(); dj == nil || dj.Username != "alice" {
+		t.Errorf("getDJ() = %v, want alice", dj)
+	}
+	if room.cache != plug.users || room.departed != plug.departed {
+		t.Error("room lost our user caches")
+	}
+}
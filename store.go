@@ -0,0 +1,96 @@
+package plugapi
+
+import (
+	"errors"
+)
+
+// Avatar is an avatar in the inventory of the logged in user
+type Avatar struct {
+	ID       string `json:"id"`       // e.g. "base01"
+	Category string `json:"category"` // e.g. "base", "hiphop"
+	Type     string `json:"type"`     // always "avatars"
+}
+
+// Badge is a badge in the inventory of the logged in user
+type Badge struct {
+	ID       string `json:"id"`
+	Category string `json:"category"`
+	Type     string `json:"type"` // always "badges"
+}
+
+// Product is an item that can be bought from the plug store
+type Product struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`     // the avatar or badge id once bought
+	Category string `json:"category"` // e.g. "base", "hiphop"
+	Type     string `json:"type"`     // "avatars", "badges" or "misc"
+	Level    int    `json:"level"`    // level required to buy it
+	PP       int    `json:"pp"`       // price in plug points
+	Cash     int    `json:"cash"`     // price in plug cash
+	Sub      int    `json:"sub"`      // 1 if only subscribers can buy it
+}
+
+// Kinds of products in the plug store
+const (
+	AvatarProduct = "avatars"
+	BadgeProduct  = "badges"
+	MiscProduct   = "misc"
+)
+
+// GetAvatars returns all the avatars owned by the logged in user
+func (plug *PlugDJ) GetAvatars() ([]Avatar, error) {
+	var avatars []Avatar
+	if err := plug.GetData(UserGetAvatarsEndpoint, &avatars, nil); err != nil {
+		return nil, err
+	}
+	return avatars, nil
+}
+
+// SetAvatar changes the avatar of the logged in user.
+// The avatar must be one of those returned by GetAvatars.
+func (plug *PlugDJ) SetAvatar(id string) error {
+	if id == "" {
+		return errors.New("go-plugapi: avatar id is empty")
+	}
+
+	if err := plug.PutData(UserSetAvatarEndpoint, map[string]string{"id": id}, nil, nil); err != nil {
+		return err
+	}
+
+	plug.updateMe(func(me *Profile) { me.AvatarID = id })
+	return nil
+}
+
+// GetBadges returns all the badges owned by the logged in user
+func (plug *PlugDJ) GetBadges() ([]Badge, error) {
+	var badges []Badge
+	if err := plug.GetData(UserGetBadgesEndpoint, &badges, nil); err != nil {
+		return nil, err
+	}
+	return badges, nil
+}
+
+// SetBadge changes the badge of the logged in user.
+// The badge must be one of those returned by GetBadges.
+func (plug *PlugDJ) SetBadge(id string) error {
+	if id == "" {
+		return errors.New("go-plugapi: badge id is empty")
+	}
+
+	if err := plug.PutData(UserSetBadgeEndpoint, map[string]string{"id": id}, nil, nil); err != nil {
+		return err
+	}
+
+	plug.updateMe(func(me *Profile) { me.Badge = id })
+	return nil
+}
+
+// GetProducts returns the products in the store of the given kind
+// (AvatarProduct, BadgeProduct or MiscProduct) and category
+func (plug *PlugDJ) GetProducts(kind, category string) ([]Product, error) {
+	var products []Product
+	if err := plug.GetData(StoreProductsEndpoint+kind+"/"+category, &products, nil); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
@@ -0,0 +1,99 @@
+package plugapi
+
+import (
+	"encoding/json"
+	log "github.com/Sirupsen/logrus"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestPlug returns a session whose REST requests go to handler
+func newTestPlug(t *testing.T, handler http.HandlerFunc) *PlugDJ {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	// we are never logged in, but requests look at our cookies
+	web := srv.Client()
+	web.Jar, _ = cookiejar.New(nil)
+
+	return &PlugDJ{
+		config: &Config{BaseURL: srv.URL},
+		Log:    log.New(),
+		web:    web,
+	}
+}
+
+// writeEnvelope replies the way plug does, with the data in an envelope
+func writeEnvelope(w http.ResponseWriter, status string, data interface{}) {
+	raw, _ := json.Marshal(data)
+	json.NewEncoder(w).Encode(apiEnvelope{Status: status, Data: raw, Meta: json.RawMessage("{}")})
+}
+
+func TestGetAvatars(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_"+UserGetAvatarsEndpoint {
+			t.Errorf("path = %s, want %s", r.URL.Path, "/_"+UserGetAvatarsEndpoint)
+		}
+		writeEnvelope(w, "ok", []Avatar{{ID: "base01", Category: "base", Type: AvatarProduct}})
+	})
+
+	avatars, err := plug.GetAvatars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(avatars) != 1 || avatars[0].ID != "base01" {
+		t.Errorf("avatars = %+v, want only base01", avatars)
+	}
+}
+
+func TestSetAvatar(t *testing.T) {
+	var body map[string]string
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/_"+UserSetAvatarEndpoint {
+			t.Errorf("request = %s %s, want PUT %s", r.Method, r.URL.Path, "/_"+UserSetAvatarEndpoint)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		writeEnvelope(w, "ok", []interface{}{})
+	})
+
+	if err := plug.SetAvatar("base02"); err != nil {
+		t.Fatal(err)
+	}
+	if body["id"] != "base02" {
+		t.Errorf("body = %v, want the avatar id", body)
+	}
+
+	if err := plug.SetAvatar(""); err == nil {
+		t.Error("empty avatar id was accepted")
+	}
+}
+
+func TestSetBadgeFails(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		writeEnvelope(w, "notFound", []string{"no such badge"})
+	})
+
+	if err := plug.SetBadge("nope"); err == nil {
+		t.Error("plug saying notFound wasn't an error")
+	}
+}
+
+func TestGetProducts(t *testing.T) {
+	plug := newTestPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		want := "/_" + StoreProductsEndpoint + BadgeProduct + "/country"
+		if r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		writeEnvelope(w, "ok", []Product{{ID: 1, Name: "gb", Type: BadgeProduct, PP: 500}})
+	})
+
+	products, err := plug.GetProducts(BadgeProduct, "country")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].PP != 500 {
+		t.Errorf("products = %+v, want the one badge", products)
+	}
+}
@@ -0,0 +1,116 @@
+package plugapi
+
+import (
+	"bytes"
+	log "github.com/Sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+)
+
+// Bodies longer than this are cut short when traced
+const maxTraceBody = 4096
+
+// Things that must never end up in our logs, in every
+// shape they can take: json keys, the variables on plug's
+// homepage and the parameter of the socket "auth" frame
+var redactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`("(?:password|csrf|_csrf|_jm)"\s*:\s*)"(?:[^"\\]|\\.)*"`),
+	regexp.MustCompile(`((?:_csrf|_jm)\s*=\s*)"[^"]*"`),
+	regexp.MustCompile(`("a"\s*:\s*"auth"\s*,\s*"p"\s*:\s*)"[^"]*"`),
+}
+
+// redact hides anything secret in the data
+func redact(data []byte) []byte {
+	for _, pattern := range redactPatterns {
+		data = pattern.ReplaceAll(data, []byte(`$1"[redacted]"`))
+	}
+	return data
+}
+
+// traceBody prepares a body to be logged
+func traceBody(data []byte) string {
+	data = redact(data)
+	if len(data) > maxTraceBody {
+		return string(data[:maxTraceBody]) + "...(truncated)"
+	}
+	return string(data)
+}
+
+// trace logs at the configured trace level, but only if tracing is on
+func (plug *PlugDJ) trace(fields log.Fields, msg string) {
+	if !plug.config.Trace {
+		return
+	}
+
+	entry := plug.Log.WithFields(fields)
+	switch plug.config.TraceLevel {
+	case log.InfoLevel:
+		entry.Infoln(msg)
+	case log.WarnLevel:
+		entry.Warnln(msg)
+	case log.ErrorLevel:
+		entry.Errorln(msg)
+	default:
+		entry.Debugln(msg)
+	}
+}
+
+// traceFrame logs a socket frame going in or out
+func (plug *PlugDJ) traceFrame(direction string, data []byte) {
+	if !plug.config.Trace {
+		return
+	}
+
+	plug.trace(log.Fields{"direction": direction, "frame": traceBody(data)}, "socket frame")
+}
+
+// tracingTransport logs all requests and
+// responses going through the transport
+type tracingTransport struct {
+	plug *PlugDJ
+	base http.RoundTripper // http.DefaultTransport if nil
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	fields := log.Fields{"method": req.Method, "url": req.URL.String()}
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		fields["body"] = traceBody(body)
+	}
+	t.plug.trace(fields, "REST request")
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		t.plug.trace(log.Fields{"method": req.Method, "url": req.URL.String(), "error": err}, "REST request failed")
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	t.plug.trace(log.Fields{
+		"method": req.Method,
+		"url":    req.URL.String(),
+		"status": resp.StatusCode,
+		"body":   traceBody(body),
+	}, "REST response")
+
+	return resp, nil
+}
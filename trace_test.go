@@ -0,0 +1,43 @@
+package plugapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in     string
+		secret string
+	}{
+		{`{"email":"a@b.c","password":"hunter2"}`, "hunter2"},
+		{`{"csrf": "abc\"def"}`, `abc\"def`},
+		{`var _csrf="0123456789", _jm="sessioncode"`, "0123456789"},
+		{`var _csrf="0123456789", _jm="sessioncode"`, "sessioncode"},
+		{`{"a":"auth","p":"sessioncode","t":1}`, "sessioncode"},
+		{`{"a": "auth", "p": "sessioncode"}`, "sessioncode"},
+	}
+
+	for _, test := range tests {
+		got := string(redact([]byte(test.in)))
+		if strings.Contains(got, test.secret) {
+			t.Errorf("redact(%s) = %s, still contains %q", test.in, got, test.secret)
+		}
+		if !strings.Contains(got, "[redacted]") {
+			t.Errorf("redact(%s) = %s, nothing redacted", test.in, got)
+		}
+	}
+
+	// everything else is left alone
+	chat := `[{"a":"chat","p":{"message":"my password is safe"}}]`
+	if got := string(redact([]byte(chat))); got != chat {
+		t.Errorf("redact(%s) = %s, want it unchanged", chat, got)
+	}
+}
+
+func TestTraceBodyTruncates(t *testing.T) {
+	body := traceBody([]byte(strings.Repeat("a", maxTraceBody+10)))
+	if !strings.HasSuffix(body, "...(truncated)") || len(body) != maxTraceBody+len("...(truncated)") {
+		t.Errorf("traceBody of a long body wasn't truncated, got %d bytes", len(body))
+	}
+}
@@ -0,0 +1,103 @@
+package plugapi
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Defaults for Config.UserCacheSize, Config.UserCacheTTL,
+// Config.DepartedCacheSize and Config.DepartedCacheTTL
+const (
+	defaultUserCacheSize     = 500
+	defaultUserCacheTTL      = time.Hour
+	defaultDepartedCacheSize = 200
+	defaultDepartedCacheTTL  = 30 * time.Minute
+)
+
+// GetUser returns the user with the given ID, whether or not they
+// are in the room. Users are cached, so plug is only asked once.
+func (plug *PlugDJ) GetUser(id int) (*User, error) {
+	if user, ok := plug.users.get(id); ok {
+		return user, nil
+	}
+
+	var data []User
+	if err := plug.GetData(UserLookupEndpoint+strconv.Itoa(id), &data, nil); err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, ErrNotFound
+	}
+
+	plug.users.add(data[0])
+	return &data[0], nil
+}
+
+// GetUsers returns the users with the given IDs. Only the ones
+// that aren't cached are asked for, and all at once. Users that
+// don't exist are left out, so fewer users may be returned.
+func (plug *PlugDJ) GetUsers(ids []int) ([]User, error) {
+	users := make([]User, 0, len(ids))
+
+	var missing []int
+	for _, id := range ids {
+		if user, ok := plug.users.get(id); ok {
+			users = append(users, *user)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(missing) == 0 {
+		return users, nil
+	}
+
+	var data []User
+	if err := plug.PostData(UserBulkEndpoint, map[string][]int{"ids": missing}, &data, nil); err != nil {
+		return nil, err
+	}
+
+	for _, user := range data {
+		plug.users.add(user)
+	}
+
+	return append(users, data...), nil
+}
+
+// GetUserHistory returns the plays of the user in the history
+// of the room, the most recent first. plug has no way of looking
+// up what a user has played elsewhere.
+func (plug *PlugDJ) GetUserHistory(id int) ([]HistoryItem, error) {
+	if id <= 0 {
+		return nil, errors.New("plugapi: invalid user id")
+	}
+
+	return plug.QueryHistory(HistoryQuery{UserID: id})
+}
+
+// newRoom makes an empty room that knows about our user caches
+func (plug *PlugDJ) newRoom() *Room {
+	room := &Room{}
+	plug.useCaches(room)
+	return room
+}
+
+// useCaches makes the room look in our user caches for
+// users that aren't in it, and remember the ones that leave
+func (plug *PlugDJ) useCaches(room *Room) {
+	room.cache = plug.users
+	room.departed = plug.departed
+}
+
+// newCache makes a user cache, using the defaults for zero values
+func newCache(size int, ttl time.Duration, defaultSize int, defaultTTL time.Duration) *userCache {
+	if size == 0 {
+		size = defaultSize
+	}
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	return newUserCache(size, ttl)
+}
@@ -2,18 +2,38 @@ package plugapi
 
 import (
 	"errors"
-	"fmt"
-	"io"
-	"io/ioutil"
 	"strconv"
+	"time"
 )
 
 type User struct {
 	ID       int    `json:"id"`
 	Role     int    `json:"role"`
 	Username string `json:"username"`
+
+	AvatarID   string    `json:"avatarID"`
+	Badge      string    `json:"badge"`
+	Blurb      string    `json:"blurb"` // the "about me" on their profile
+	GlobalRole int       `json:"gRole"` // plug staff, brand ambassadors etc.
+	Guest      bool      `json:"guest"`
+	Joined     Timestamp `json:"joined"`   // when they signed up
+	Language   string    `json:"language"` // e.g. "en"
+	Level      int       `json:"level"`
+	Silver     bool      `json:"silver"` // silver subscriber
+	Slug       string    `json:"slug"`   // profile url shortname
+	Sub        int       `json:"sub"`    // 1 if subscribed
 }
 
+// Roles a user can have in a room, as in User.Role
+const (
+	NoRole         = 0
+	ResidentDJRole = 1000
+	BouncerRole    = 2000
+	ManagerRole    = 3000
+	CohostRole     = 4000
+	HostRole       = 5000
+)
+
 // Booth is the data about the current queue
 type Booth struct {
 	CurrentDJ   int   `json:"currentDJ"`
@@ -34,10 +54,10 @@ type Media struct {
 
 // Playback metadata about an existing play (note, not the song)
 type Playback struct {
-	HistoryID  string `json:"historyID"`
-	Media      Media  `json:"media"`
-	PlaylistID int    `json:"playlistID"` // default: -1
-	StartTime  string `json:"startTime"`
+	HistoryID  string    `json:"historyID"`
+	Media      Media     `json:"media"`
+	PlaylistID int       `json:"playlistID"` // default: -1
+	StartTime  time.Time `json:"startTime"`
 }
 
 // PlayScore is the score of an individual song
@@ -58,7 +78,7 @@ type HistoryItem struct {
 		Slug string `json:"slug"`
 	} `json:"room"`
 	Score     PlayScore `json:"score"`
-	Timestamp string    `json:"timestamp"` // Format: xx-xx-xx
+	Timestamp time.Time `json:"timestamp"`
 	User      struct {
 		ID       int    `json:"id"`
 		Username string `json:"username"`
@@ -80,6 +100,10 @@ const (
 	HistoryEndpoint    string = "/rooms/history"
 	PlaylistEndpoint   string = "/playlists"
 
+	FriendsEndpoint       string = "/friends"
+	FriendIgnoreEndpoint  string = "/friends/ignore"
+	FriendInvitesEndpoint string = "/friends/invites"
+
 	ModerateAddDJEndpoint       string = "/booth/add"
 	ModerateBanEndpoint         string = "/bans/add"
 	ModerateBoothEndpoint       string = "/booth"
@@ -95,24 +119,26 @@ const (
 	SkipMeEndpoint         string = "/booth/skip/me"
 	RoomCycleBoothEndpoint string = "/booth/cycle"
 	RoomLockBoothEndpoint  string = "/booth/lock"
+	RoomFavoritesEndpoint  string = "/rooms/favorites"
 	RoomInfoEndpoint       string = "/rooms/update"
 	RoomJoinEndpoint       string = "/rooms/join"
+	RoomListEndpoint       string = "/rooms"
 	RoomStateEndpoint      string = "/rooms/state"
 
+	StoreProductsEndpoint string = "/store/products/"
+
+	UserBlurbEndpoint      string = "/profile/blurb"
+	UserBulkEndpoint       string = "/users/bulk"
 	UserInfoEndpoint       string = "/users/me"
+	UserLanguageEndpoint   string = "/users/language"
+	UserLookupEndpoint     string = "/users/"
+	UserSettingsEndpoint   string = "/users/settings"
 	UserGetAvatarsEndpoint string = "/store/inventory/avatars"
+	UserGetBadgesEndpoint  string = "/store/inventory/badges"
 	UserSetAvatarEndpoint  string = "/users/avatar"
+	UserSetBadgeEndpoint   string = "/users/badge"
 )
 
-// TODO: quickread is a debug function
-// to print and return all of an
-// io.Reader's contents
-func quickread(reader io.Reader) []byte {
-	b, _ := ioutil.ReadAll(reader)
-	fmt.Printf("%s\n", b)
-	return b
-}
-
 type IntBool bool
 
 func (b *IntBool) UnmarshalJSON(data []byte) error {
@@ -0,0 +1,208 @@
+package plugapi
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// How long after being taken out of the waitlist leaving the room still
+// counts as leaving from the waitlist, as plug may send the djListUpdate
+// before the userLeave
+const vanishedWindow = 5 * time.Second
+
+// savedSpot is where someone was in the waitlist, starting at zero
+type savedSpot struct {
+	position int
+	at       time.Time
+}
+
+// SavedSpot returns where in the waitlist the user will be put back if
+// they rejoin the room, and until when. See Config.WaitlistGrace.
+func (plug *PlugDJ) SavedSpot(userID int) (position int, until time.Time, ok bool) {
+	plug.spotLock.Lock()
+	defer plug.spotLock.Unlock()
+
+	spot, ok := plug.spots[userID]
+	if !ok || time.Since(spot.at) > plug.config.WaitlistGrace {
+		return 0, time.Time{}, false
+	}
+	return spot.position, spot.at.Add(plug.config.WaitlistGrace), true
+}
+
+// updateWaitlist replaces the waitlist, remembering
+// where anyone that was taken out of it was
+func (plug *PlugDJ) updateWaitlist(waiting []int) {
+	plug.Room.Lock()
+	old := plug.Room.Booth.WaitingDJs
+	current := plug.Room.Booth.CurrentDJ
+	plug.Room.Booth.WaitingDJs = waiting
+	plug.Room.Unlock()
+
+	if plug.config.WaitlistGrace <= 0 {
+		return
+	}
+
+	// whoever is playing now left the waitlist for the booth
+	still := map[int]bool{current: true}
+	for _, uid := range waiting {
+		still[uid] = true
+	}
+
+	now := time.Now()
+	plug.spotLock.Lock()
+	defer plug.spotLock.Unlock()
+
+	for uid, spot := range plug.vanished {
+		if now.Sub(spot.at) > vanishedWindow {
+			delete(plug.vanished, uid)
+		}
+	}
+
+	for i, uid := range old {
+		if !still[uid] {
+			plug.vanished[uid] = savedSpot{i, now}
+		}
+	}
+}
+
+// saveSpot remembers where the user was in the waitlist as they leave
+func (plug *PlugDJ) saveSpot(userID int) {
+	if plug.config.WaitlistGrace <= 0 {
+		return
+	}
+
+	now := time.Now()
+	position := plug.Room.waitlistPosition(userID)
+
+	plug.spotLock.Lock()
+	defer plug.spotLock.Unlock()
+
+	for uid, spot := range plug.spots {
+		if now.Sub(spot.at) > plug.config.WaitlistGrace {
+			delete(plug.spots, uid)
+		}
+	}
+
+	if position < 0 {
+		spot, ok := plug.vanished[userID]
+		if !ok || now.Sub(spot.at) > vanishedWindow {
+			return
+		}
+		position = spot.position
+	}
+
+	delete(plug.vanished, userID)
+	plug.spots[userID] = savedSpot{position, now}
+}
+
+// shiftSpots moves every saved spot up one, as the waitlist just did
+func (plug *PlugDJ) shiftSpots() {
+	plug.spotLock.Lock()
+	defer plug.spotLock.Unlock()
+
+	for uid, spot := range plug.spots {
+		if spot.position > 0 {
+			spot.position--
+			plug.spots[uid] = spot
+		}
+	}
+}
+
+// The reason given in the audit log for putting someone back in the waitlist
+const restoreReason = "rejoined within the waitlist grace"
+
+// takeSpot returns the saved spot of the user and forgets it, so
+// that only one of two quick rejoins gets to put them back
+func (plug *PlugDJ) takeSpot(userID int) (savedSpot, bool) {
+	plug.spotLock.Lock()
+	defer plug.spotLock.Unlock()
+
+	spot, ok := plug.spots[userID]
+	if !ok || time.Since(spot.at) > plug.config.WaitlistGrace {
+		return savedSpot{}, false
+	}
+
+	delete(plug.spots, userID)
+	return spot, true
+}
+
+// putSpotBack saves a spot taken with takeSpot again, unless
+// the user has left and had another spot saved since
+func (plug *PlugDJ) putSpotBack(userID int, spot savedSpot) {
+	plug.spotLock.Lock()
+	defer plug.spotLock.Unlock()
+
+	if _, ok := plug.spots[userID]; !ok {
+		plug.spots[userID] = spot
+	}
+}
+
+// restoreSpot puts the user back where they were in the
+// waitlist if they left less than Config.WaitlistGrace ago.
+// If we can't, their spot stays saved until it expires.
+func (plug *PlugDJ) restoreSpot(userID int) {
+	spot, ok := plug.takeSpot(userID)
+	if !ok {
+		return
+	}
+	position := spot.position
+
+	logger := plug.Log.WithFields(log.Fields{"uid": userID, "position": position})
+	if err := plug.moderateAddDJ(userID, restoreReason); err != nil {
+		logger.WithField("error", err).Warnln("could not put user back in the waitlist")
+		plug.putSpotBack(userID, spot)
+		return
+	}
+
+	// they're at the back now, which may be where they were
+	plug.Room.RLock()
+	back := len(plug.Room.Booth.WaitingDJs)
+	plug.Room.RUnlock()
+
+	if position < back {
+		if err := plug.moderateMoveDJ(userID, position, restoreReason); err != nil {
+			logger.WithField("error", err).Warnln("could not move user back to their waitlist spot")
+			return
+		}
+	}
+
+	logger.Infoln("put user back in their waitlist spot")
+}
+
+// isSpotCommand checks if the message is Config.SpotCommand
+func (plug *PlugDJ) isSpotCommand(message string) bool {
+	command := plug.config.SpotCommand
+	return command != "" && strings.EqualFold(strings.TrimSpace(message), command)
+}
+
+// replySpot tells whoever sent the chat message about their waitlist spot
+func (plug *PlugDJ) replySpot(chat ChatPayload) {
+	if chat.User == nil {
+		return
+	}
+
+	var text string
+	if position, until, ok := plug.SavedSpot(chat.User.ID); ok {
+		left := time.Until(until).Round(time.Second)
+		text = fmt.Sprintf("your spot (#%d) is saved for another %s", position+1, left)
+	} else if position := plug.Room.waitlistPosition(chat.User.ID); position >= 0 {
+		text = fmt.Sprintf("you are #%d in the waitlist", position+1)
+	} else {
+		text = "you don't have a spot in the waitlist"
+	}
+
+	if err := plug.Reply(chat, text); err != nil {
+		plug.Log.WithField("error", err).Warnln("could not reply to spot command")
+	}
+}
+
+// forgetSpots forgets all saved spots, they mean nothing in another room
+func (plug *PlugDJ) forgetSpots() {
+	plug.spotLock.Lock()
+	defer plug.spotLock.Unlock()
+
+	plug.spots = make(map[int]savedSpot)
+	plug.vanished = make(map[int]savedSpot)
+}
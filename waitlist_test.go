@@ -0,0 +1,191 @@
+package plugapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newWaitlistPlug makes a plug where alice (2) is playing
+// and bob (3) and carol (4) are waiting, in that order
+func newWaitlistPlug(t *testing.T, handler http.HandlerFunc) *PlugDJ {
+	plug := newTestPlug(t, handler)
+	plug.config.WaitlistGrace = time.Minute
+	plug.config.StuckTrackGrace = -1
+	plug.clock = &ServerClock{}
+	plug.eventFuncs = make(map[Event]ProcessPayloadFunc)
+	plug.spots = make(map[int]savedSpot)
+	plug.vanished = make(map[int]savedSpot)
+	plug.Room = &Room{}
+	plug.Room.SetUsers([]User{{ID: 2, Username: "alice"}, {ID: 3, Username: "bob"}, {ID: 4, Username: "carol"}})
+	plug.Room.Booth = Booth{CurrentDJ: 2, WaitingDJs: []int{3, 4}}
+	return plug
+}
+
+func TestSaveSpot(t *testing.T) {
+	plug := newWaitlistPlug(t, nil)
+
+	handleAction_userLeave(plug, json.RawMessage(`4`))
+	if position, until, ok := plug.SavedSpot(4); !ok || position != 1 || time.Until(until) <= 0 {
+		t.Errorf("SavedSpot(4) = %d, %s, %t, want #1 saved", position, until, ok)
+	}
+
+	// leaving the booth isn't leaving the waitlist
+	handleAction_userLeave(plug, json.RawMessage(`2`))
+	if _, _, ok := plug.SavedSpot(2); ok {
+		t.Error("the DJ's spot was saved")
+	}
+
+	// the waitlist moving up moves the saved spot up too
+	handleAction_advance(plug, json.RawMessage(`{"c":3,"d":[],"h":"one","m":{"cid":"a","duration":200},"p":1,"t":"2018-01-01 12:00:00.000000"}`))
+	if position, _, _ := plug.SavedSpot(4); position != 0 {
+		t.Errorf("saved spot is #%d after an advance, want #0", position)
+	}
+
+	plug.forgetSpots()
+	if _, _, ok := plug.SavedSpot(4); ok {
+		t.Error("spot is still saved after forgetting them")
+	}
+}
+
+func TestSaveSpotAfterListUpdate(t *testing.T) {
+	plug := newWaitlistPlug(t, nil)
+
+	// plug may take them out of the waitlist before saying they left
+	handleAction_djListUpdate(plug, json.RawMessage(`[4]`))
+	handleAction_userLeave(plug, json.RawMessage(`3`))
+	if position, _, ok := plug.SavedSpot(3); !ok || position != 0 {
+		t.Errorf("SavedSpot(3) = %d, %t, want #0 saved", position, ok)
+	}
+
+	// but not long before
+	plug.Room.Booth.WaitingDJs = nil
+	plug.vanished[4] = savedSpot{0, time.Now().Add(-2 * vanishedWindow)}
+	handleAction_userLeave(plug, json.RawMessage(`4`))
+	if _, _, ok := plug.SavedSpot(4); ok {
+		t.Error("spot was saved for someone that left the waitlist long ago")
+	}
+}
+
+func TestSaveSpotDisabled(t *testing.T) {
+	plug := newWaitlistPlug(t, nil)
+	plug.config.WaitlistGrace = 0
+
+	handleAction_userLeave(plug, json.RawMessage(`3`))
+	if _, _, ok := plug.SavedSpot(3); ok {
+		t.Error("spot was saved without a WaitlistGrace")
+	}
+}
+
+func TestRestoreSpot(t *testing.T) {
+	var lock sync.Mutex
+	var requests []string
+	plug := newWaitlistPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		requests = append(requests, r.URL.Path[len("/_"):]+" "+strings.TrimSpace(string(body)))
+		lock.Unlock()
+		writeEnvelope(w, "ok", []struct{}{})
+	})
+
+	plug.saveSpot(3)
+	plug.Room.removeUser(3)
+	plug.updateWaitlist([]int{4})
+
+	plug.Room.addUser(User{ID: 3, Username: "bob"})
+	plug.restoreSpot(3)
+
+	lock.Lock()
+	got := strings.Join(requests, ", ")
+	lock.Unlock()
+	want := ModerateAddDJEndpoint + ` {"id":3}, ` + ModerateMoveDJEndpoint + ` {"position":0,"userID":3}`
+	if got != want {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+
+	if _, _, ok := plug.SavedSpot(3); ok {
+		t.Error("spot is still saved after being restored")
+	}
+}
+
+func TestRestoreSpotExpired(t *testing.T) {
+	asked := false
+	plug := newWaitlistPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		asked = true
+		writeEnvelope(w, "ok", []struct{}{})
+	})
+	plug.spots[3] = savedSpot{0, time.Now().Add(-2 * time.Minute)}
+
+	plug.restoreSpot(3)
+	if asked {
+		t.Error("user was put back after WaitlistGrace")
+	}
+}
+
+func TestRestoreSpotOnce(t *testing.T) {
+	var lock sync.Mutex
+	adds := 0
+	plug := newWaitlistPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		if r.URL.Path == "/_"+ModerateAddDJEndpoint {
+			adds++
+		}
+		lock.Unlock()
+		writeEnvelope(w, "ok", []struct{}{})
+	})
+	plug.spots[3] = savedSpot{0, time.Now()}
+
+	// they rejoined twice in quick succession
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			plug.restoreSpot(3)
+		}()
+	}
+	wg.Wait()
+
+	if adds != 1 {
+		t.Errorf("user was put back %d times, want once", adds)
+	}
+}
+
+func TestRestoreSpotFails(t *testing.T) {
+	plug := newWaitlistPlug(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		writeEnvelope(w, "notAuthorized", []struct{}{})
+	})
+	plug.spots[3] = savedSpot{1, time.Now()}
+
+	plug.restoreSpot(3)
+	if position, _, ok := plug.SavedSpot(3); !ok || position != 1 {
+		t.Errorf("SavedSpot(3) = %d, %t, want #1 still saved", position, ok)
+	}
+}
+
+func TestIsSpotCommand(t *testing.T) {
+	plug := &PlugDJ{config: &Config{SpotCommand: "!spot"}}
+	tests := map[string]bool{
+		"!spot":       true,
+		"  !SPOT ":    true,
+		"!spots":      false,
+		"where !spot": false,
+		"":            false,
+	}
+
+	for message, want := range tests {
+		if got := plug.isSpotCommand(message); got != want {
+			t.Errorf("isSpotCommand(%q) = %t, want %t", message, got, want)
+		}
+	}
+
+	plug.config.SpotCommand = ""
+	if plug.isSpotCommand("") {
+		t.Error("empty SpotCommand matched")
+	}
+}